@@ -0,0 +1,416 @@
+// Command compgen generates component boilerplate from annotated types.  It is
+// intended to be run with `go generate`, by adding the following to a file in
+// the package:
+//
+//	//go:generate go run comps/cmd/compgen
+//
+// The component type is annotated with the name of the ComponentImpl variable
+// it implements:
+//
+//	//comp:component Main [mailbox] [default=<method>]
+//	type logger struct { ... }
+//
+// and each message type it accepts is annotated with the same name, and
+// optionally the type of the response:
+//
+//	//comp:message Main [response=<type>]
+//	type Output struct { ... }
+//
+// For each component, compgen generates, in comp_gen.go:
+//
+//   - `var _ core.Component` and `var _ core.ComponentReference` assertions;
+//   - NewReference, returning the component itself;
+//   - Request, which dispatches each message type to a handler method named
+//     handle<Type>, with signature `(ctx, msg <Type>) error`, or
+//     `(ctx, msg <Type>) (<response>, error)` if a response type is given.
+//     Other messages go to the `default` method, with the signature of
+//     Request, if given, and are otherwise rejected;
+//   - RequestAsync, which calls Request and reports failures with
+//     core.ReportAsyncFailure, or with the `mailbox` option, puts the message in
+//     the component's `mailbox` field (and Mailbox, implementing
+//     core.MailboxComponent); and
+//   - a typed client, <Name>Client, with a method for each message type, and
+//     a Wrap<Name> helper that creates a client from a deps map.
+//
+// The component must still define its Done method, and a goroutine to read
+// from its mailbox if it has one (see core.HandleEnvelope).
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// outputFile is the name of the generated file
+const outputFile = "comp_gen.go"
+
+type component struct {
+	Name       string
+	Type       string
+	Receiver   string
+	Path       string
+	Mailbox    bool
+	Default    string
+	Messages   []message
+	ClientType string
+}
+
+type message struct {
+	Type     string
+	Response string
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("compgen: ")
+
+	dir := "."
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+
+	src, err := generate(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, outputFile), src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the contents of the generated file for the package in dir.
+func generate(dir string) ([]byte, error) {
+	pkgName, components, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(components) == 0 {
+		return nil, fmt.Errorf("no //comp:component annotations found in %s", dir)
+	}
+
+	var buf bytes.Buffer
+	err = fileTemplate.Execute(&buf, struct {
+		Package    string
+		Components []*component
+		NeedFmt    bool
+	}{pkgName, components, needFmt(components)})
+	if err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %s\n%s", err, buf.String())
+	}
+	return src, nil
+}
+
+// load parses the package in dir and gathers the annotated components.
+func load(dir string) (string, []*component, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(filenames)
+
+	fset := token.NewFileSet()
+	pkgName := ""
+	files := map[string]*ast.File{}
+	for _, filename := range filenames {
+		base := filepath.Base(filename)
+		if base == outputFile || strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+		if err != nil {
+			return "", nil, err
+		}
+		if pkgName == "" {
+			pkgName = file.Name.Name
+		} else if file.Name.Name != pkgName {
+			return "", nil, fmt.Errorf("found packages %s and %s in %s", pkgName, file.Name.Name, dir)
+		}
+		files[filename] = file
+	}
+
+	components := map[string]*component{}
+	messages := map[string][]message{}
+	paths := map[string]string{}
+
+	for _, filename := range filenames {
+		file, found := files[filename]
+		if !found {
+			continue
+		}
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					doc := spec.Doc
+					if doc == nil && len(gd.Specs) == 1 {
+						doc = gd.Doc
+					}
+					for _, directive := range directives(doc) {
+						if err := apply(directive, spec.Name.Name, components, messages); err != nil {
+							return "", nil, fmt.Errorf("%s: %s", fset.Position(spec.Pos()), err)
+						}
+					}
+				case *ast.ValueSpec:
+					// record the Path of each ComponentImpl literal
+					for i, value := range spec.Values {
+						lit, ok := value.(*ast.CompositeLit)
+						if !ok || i >= len(spec.Names) || !isComponentImpl(lit.Type) {
+							continue
+						}
+						for _, elt := range lit.Elts {
+							kv, ok := elt.(*ast.KeyValueExpr)
+							if !ok {
+								continue
+							}
+							if key, ok := kv.Key.(*ast.Ident); ok && key.Name == "Path" {
+								var buf bytes.Buffer
+								printer.Fprint(&buf, fset, kv.Value)
+								paths[spec.Names[0].Name] = buf.String()
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	rv := []*component{}
+	for name, comp := range components {
+		path, found := paths[name]
+		if !found {
+			return "", nil, fmt.Errorf("no ComponentImpl variable named %s", name)
+		}
+		comp.Path = path
+		comp.Messages = messages[name]
+		delete(messages, name)
+		rv = append(rv, comp)
+	}
+	for name := range messages {
+		return "", nil, fmt.Errorf("//comp:message annotations for %s, but no //comp:component", name)
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Name < rv[j].Name })
+
+	return pkgName, rv, nil
+}
+
+// directives returns the `//comp:` directives in a comment group, without the
+// `//comp:` prefix.
+func directives(doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	var rv []string
+	for _, c := range doc.List {
+		if strings.HasPrefix(c.Text, "//comp:") {
+			rv = append(rv, strings.TrimPrefix(c.Text, "//comp:"))
+		}
+	}
+	return rv
+}
+
+// apply applies a single directive on the named type.
+func apply(directive, typeName string, components map[string]*component, messages map[string][]message) error {
+	fields := strings.Fields(directive)
+	if len(fields) < 2 {
+		return fmt.Errorf("malformed directive //comp:%s", directive)
+	}
+	kind, name, opts := fields[0], fields[1], fields[2:]
+
+	switch kind {
+	case "component":
+		if _, found := components[name]; found {
+			return fmt.Errorf("duplicate //comp:component for %s", name)
+		}
+		comp := &component{
+			Name:       name,
+			Type:       typeName,
+			Receiver:   strings.ToLower(typeName[:1]),
+			ClientType: name + "Client",
+		}
+		for _, opt := range opts {
+			switch {
+			case opt == "mailbox":
+				comp.Mailbox = true
+			case strings.HasPrefix(opt, "default="):
+				comp.Default = strings.TrimPrefix(opt, "default=")
+			default:
+				return fmt.Errorf("unknown option %q", opt)
+			}
+		}
+		components[name] = comp
+
+	case "message":
+		msg := message{Type: typeName}
+		for _, opt := range opts {
+			switch {
+			case strings.HasPrefix(opt, "response="):
+				msg.Response = strings.TrimPrefix(opt, "response=")
+			default:
+				return fmt.Errorf("unknown option %q", opt)
+			}
+		}
+		messages[name] = append(messages[name], msg)
+
+	default:
+		return fmt.Errorf("unknown directive //comp:%s", kind)
+	}
+	return nil
+}
+
+// isComponentImpl determines whether a type expression is core.ComponentImpl.
+func isComponentImpl(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "ComponentImpl" {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "core"
+}
+
+// needFmt determines whether the generated code uses the fmt package.
+func needFmt(components []*component) bool {
+	for _, comp := range components {
+		if comp.Default == "" {
+			return true
+		}
+		for _, msg := range comp.Messages {
+			if msg.Response != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by compgen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"comps/core"
+	"context"
+{{- if .NeedFmt}}
+	"fmt"
+{{- end}}
+)
+{{range .Components}}{{$c := .}}
+var _ core.Component = &{{.Type}}{}
+var _ core.ComponentReference = &{{.Type}}{}
+{{- if .Mailbox}}
+var _ core.MailboxComponent = &{{.Type}}{}
+{{- end}}
+
+// NewReference implements core.Component#NewReference.
+func ({{.Receiver}} *{{.Type}}) NewReference() core.ComponentReference {
+	return {{.Receiver}}
+}
+
+// Request implements core.ComponentReference#Request.
+func ({{.Receiver}} *{{.Type}}) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	switch v := msg.(type) {
+{{- range .Messages}}
+	case {{.Type}}:
+{{- if .Response}}
+		return {{$c.Receiver}}.handle{{.Type}}(ctx, v)
+{{- else}}
+		return nil, {{$c.Receiver}}.handle{{.Type}}(ctx, v)
+{{- end}}
+{{- end}}
+	default:
+{{- if .Default}}
+		return {{.Receiver}}.{{.Default}}(ctx, v)
+{{- else}}
+		return nil, fmt.Errorf("Unrecognized message type %T", v)
+{{- end}}
+	}
+}
+
+// RequestAsync implements core.ComponentReference#RequestAsync.
+func ({{.Receiver}} *{{.Type}}) RequestAsync(ctx context.Context, msg core.Message) {
+{{- if .Mailbox}}
+	if err := {{.Receiver}}.mailbox.Put(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+{{- else}}
+	if _, err := {{.Receiver}}.Request(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+{{- end}}
+}
+{{- if .Mailbox}}
+
+// Mailbox implements core.MailboxComponent#Mailbox.
+func ({{.Receiver}} *{{.Type}}) Mailbox() *core.Mailbox {
+	return {{.Receiver}}.mailbox
+}
+{{- end}}
+
+// {{.ClientType}} is a typed client for the {{.Name}} component.
+type {{.ClientType}} struct {
+	ref core.ComponentReference
+}
+
+// Wrap{{.Name}} returns a {{.ClientType}} for the {{.Name}} component, which must
+// be among the given dependencies.
+func Wrap{{.Name}}(deps map[core.ComponentPath]core.ComponentReference) {{.ClientType}} {
+	return {{.ClientType}}{ref: deps[{{.Path}}]}
+}
+
+// New{{.ClientType}} returns a {{.ClientType}} wrapping the given reference.
+func New{{.ClientType}}(ref core.ComponentReference) {{.ClientType}} {
+	return {{.ClientType}}{ref: ref}
+}
+
+// Reference returns the wrapped reference.
+func (c {{.ClientType}}) Reference() core.ComponentReference {
+	return c.ref
+}
+{{range .Messages}}
+{{- if .Response}}
+// {{.Type}} sends msg and waits for the response.
+func (c {{$c.ClientType}}) {{.Type}}(ctx context.Context, msg {{.Type}}) ({{.Response}}, error) {
+	var zero {{.Response}}
+	rsp, err := c.ref.Request(ctx, msg)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := rsp.({{.Response}})
+	if !ok {
+		return zero, fmt.Errorf("Unexpected response type %T", rsp)
+	}
+	return typed, nil
+}
+{{- else}}
+// {{.Type}} sends msg and waits for it to be handled.
+func (c {{$c.ClientType}}) {{.Type}}(ctx context.Context, msg {{.Type}}) error {
+	_, err := c.ref.Request(ctx, msg)
+	return err
+}
+{{- end}}
+
+// {{.Type}}Async sends msg without waiting.
+func (c {{$c.ClientType}}) {{.Type}}Async(ctx context.Context, msg {{.Type}}) {
+	c.ref.RequestAsync(ctx, msg)
+}
+{{end}}
+{{- end}}`))
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestGenerateGolden(t *testing.T) {
+	src, err := generate("testdata/chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "chat", outputFile+".golden")
+	if *update {
+		if err := ioutil.WriteFile(golden, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Errorf("generated code differs from %s (run with -update to accept):\n%s", golden, src)
+	}
+}
+
+// TestGeneratedFilesCurrent checks that the generated files in the repository
+// match what compgen would generate now.
+func TestGeneratedFilesCurrent(t *testing.T) {
+	for _, dir := range []string{"../../comp/logger", "../../core/comp/debug"} {
+		src, err := generate(dir)
+		if err != nil {
+			t.Fatalf("%s: %s", dir, err)
+		}
+		current, err := ioutil.ReadFile(filepath.Join(dir, outputFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(src, current) {
+			t.Errorf("%s is out of date; run go generate", filepath.Join(dir, outputFile))
+		}
+	}
+}
+
+func TestGenerateRejectsOrphanMessages(t *testing.T) {
+	_, err := generate("testdata/orphan")
+	if err == nil || !strings.Contains(err.Error(), "no //comp:component") {
+		t.Fatalf("got %v, want an error about the missing //comp:component", err)
+	}
+}
@@ -0,0 +1,50 @@
+// Package chat exercises every compgen option; its generated file is
+// compared with comp_gen.go.golden.
+package chat
+
+import (
+	"comps/core"
+	"context"
+)
+
+var Room = core.ComponentImpl{
+	Path: "chat.Room",
+}
+
+var Directory = core.ComponentImpl{
+	Path: componentPath,
+}
+
+var componentPath core.ComponentPath = "chat.Directory"
+
+//comp:component Room mailbox default=handleOther
+type room struct {
+	mailbox *core.Mailbox
+}
+
+func (r *room) handleOther(ctx context.Context, msg core.Message) (core.Message, error) {
+	return nil, nil
+}
+
+//comp:message Room
+type Say struct {
+	Text string
+}
+
+//comp:component Directory
+type directory struct{}
+
+//comp:message Directory response=Joined
+type Join struct {
+	Name string
+}
+
+// Joined is the response to Join.
+type Joined struct {
+	Count int
+}
+
+//comp:message Directory
+type Leave struct {
+	Name string
+}
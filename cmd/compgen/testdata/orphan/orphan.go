@@ -0,0 +1,4 @@
+package orphan
+
+//comp:message Main
+type Ping struct{}
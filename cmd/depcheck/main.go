@@ -0,0 +1,22 @@
+// Command depcheck checks that each core.ComponentImpl's Dependencies match
+// the way its Start function uses the deps map.  See the
+// comps/core/depcheck package for details.
+//
+// Run it on the packages to check:
+//
+//	go run ./cmd/depcheck ./...
+//
+// or as a vet tool:
+//
+//	go build -o depcheck ./cmd/depcheck && go vet -vettool=$(pwd)/depcheck ./...
+package main
+
+import (
+	"comps/core/depcheck"
+
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(depcheck.Analyzer)
+}
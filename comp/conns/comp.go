@@ -6,7 +6,7 @@ import (
 	"comps/core"
 	"context"
 	"fmt"
-	"net"
+	"time"
 )
 
 var componentPath core.ComponentPath = "comp/conns.Main"
@@ -18,12 +18,16 @@ var componentPath core.ComponentPath = "comp/conns.Main"
 // and handles the connection until EOF.
 var Main = core.ComponentImpl{
 	Path:         componentPath,
-	Dependencies: []core.ComponentPath{"comp/logger.Main", "comp/users.Main"},
-	Start: func(orch *core.Orchestrator, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+	Dependencies: []core.ComponentPath{"comp/users.Main"},
+	Timeouts: map[core.ComponentPath]time.Duration{
+		"comp/users.Main": 5 * time.Second,
+	},
+	Requires: []core.Service{logger.Service},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
 		c := &component{
-			logger:        logger.Wrap(deps),
+			logger:        logger.NewMainClient(host.Service(logger.Service)),
 			users:         deps["comp/users.Main"],
-			newConnection: make(chan net.Conn, 5),
+			newConnection: core.NewMailbox(core.MailboxConfig{Capacity: 5, Overflow: core.BlockOverflow}),
 			incoming:      make(chan incoming, 5),
 			outgoing:      make(chan outgoing, 5),
 			ctx:           ctx,
@@ -36,10 +40,10 @@ var Main = core.ComponentImpl{
 
 type component struct {
 	core.BaseComponent
-	logger logger.Wrapper
+	logger logger.MainClient
 	users  core.ComponentReference
 
-	newConnection chan net.Conn
+	newConnection *core.Mailbox
 	incoming      chan incoming
 	outgoing      chan outgoing
 
@@ -48,6 +52,7 @@ type component struct {
 }
 
 var _ core.Component = &component{}
+var _ core.MailboxComponent = &component{}
 
 // NewReference implements core.Component#NewReference.
 func (c *component) NewReference() core.ComponentReference {
@@ -59,40 +64,52 @@ func (c *component) Done() <-chan struct{} {
 	return c.done
 }
 
+// Mailbox implements core.MailboxComponent#Mailbox.
+func (c *component) Mailbox() *core.Mailbox {
+	return c.newConnection
+}
+
 func (c *component) run() {
 	defer close(c.done)
 	nextUser := 1
 	conns := map[int]connection{}
 	for {
 		select {
-		case netconn := <-c.newConnection:
+		case env := <-c.newConnection.C():
+			netconn := env.Msg.(Connection).Conn
 			cid := nextUser
 			nextUser++
 			outgoingChan := make(chan string, 5)
-			conn := connection{netconn, cid, outgoingChan}
+			conn := connection{netconn, cid, outgoingChan, make(chan struct{})}
 			conns[cid] = conn
 			go conn.run(c.incoming)
 			c.users.RequestAsync(context.Background(),
 				users.NewUser{
 					Cid: cid,
 					SendMessage: func(msg string) {
-						c.outgoing <- outgoing{cid: cid, line: msg}
+						select {
+						case c.outgoing <- outgoing{cid: cid, line: msg}:
+						case <-c.ctx.Done():
+						}
 					},
 				})
 
 		case out := <-c.outgoing:
 			conn, found := conns[out.cid]
 			if found {
-				conn.outgoing <- out.line
+				select {
+				case conn.outgoing <- out.line:
+				case <-conn.closed:
+				}
 			}
 
 		case inc := <-c.incoming:
 			if inc.close {
-				c.logger.Output(fmt.Sprintf("Got close from %d", inc.cid))
+				c.logger.OutputAsync(context.Background(), logger.Output{Message: fmt.Sprintf("Got close from %d", inc.cid)})
 				c.users.RequestAsync(context.Background(), users.UserGone{Cid: inc.cid})
 				delete(conns, inc.cid)
 			} else {
-				c.logger.Output(fmt.Sprintf("Got message %#v from %d", inc.line, inc.cid))
+				c.logger.OutputAsync(context.Background(), logger.Output{Message: fmt.Sprintf("Got message %#v from %d", inc.line, inc.cid)})
 				c.users.RequestAsync(context.Background(), users.UserMessage{Cid: inc.cid, Message: inc.line})
 			}
 		case <-c.ctx.Done():
@@ -104,7 +121,7 @@ func (c *component) run() {
 			for len(conns) > 0 {
 				inc := <-c.incoming
 				if inc.close {
-					c.logger.Output(fmt.Sprintf("Got close from %d", inc.cid))
+					c.logger.OutputAsync(context.Background(), logger.Output{Message: fmt.Sprintf("Got close from %d", inc.cid)})
 					c.users.RequestAsync(context.Background(), users.UserGone{Cid: inc.cid})
 					delete(conns, inc.cid)
 					if len(conns) == 0 {
@@ -4,26 +4,20 @@ import (
 	"comps/core"
 	"context"
 	"fmt"
-	"net"
 )
 
 type connReference struct {
 	core.BaseComponentReference
-	newConnection chan<- net.Conn
+	newConnection *core.Mailbox
 }
 
 var _ core.ComponentReference = &connReference{}
 
 // Request implements core.ComponentReference#Request.
 func (c *connReference) Request(ctx context.Context, msg core.Message) (core.Message, error) {
-	switch v := msg.(type) {
+	switch msg.(type) {
 	case Connection:
-		select {
-		case c.newConnection <- v.Conn:
-			return nil, nil
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		}
+		return nil, c.newConnection.Put(ctx, msg)
 	default:
 		return nil, fmt.Errorf("Unrecognized message type %T", msg)
 	}
@@ -31,5 +25,7 @@ func (c *connReference) Request(ctx context.Context, msg core.Message) (core.Mes
 
 // RequestAsync implements core.ComponentReference#RequestAsync.
 func (c *connReference) RequestAsync(ctx context.Context, msg core.Message) {
-	c.Request(ctx, msg)
+	if _, err := c.Request(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
 }
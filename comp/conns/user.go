@@ -9,13 +9,22 @@ type connection struct {
 	conn     net.Conn
 	cid      int
 	outgoing chan string
+
+	// closed is closed when the remote end has closed the connection
+	closed chan struct{}
 }
 
 func (c *connection) run(incomingChan chan<- incoming) {
-	// send outgoing messages to the remote end until error
+	// send outgoing messages to the remote end until error or close
 	go func() {
 		for {
-			msg := append([]byte(<-c.outgoing), '\n')
+			var msg []byte
+			select {
+			case line := <-c.outgoing:
+				msg = append([]byte(line), '\n')
+			case <-c.closed:
+				return
+			}
 			for len(msg) > 0 {
 				n, err := c.conn.Write(msg)
 				if err != nil {
@@ -35,8 +44,9 @@ func (c *connection) run(incomingChan chan<- incoming) {
 		}
 	}
 
-	// close the conn, for good measure
+	// close the conn, for good measure, and stop the writer
 	_ = c.conn.Close()
+	close(c.closed)
 
 	incomingChan <- incoming{
 		cid:   c.cid,
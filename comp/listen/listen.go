@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
 )
 
 var componentPath core.ComponentPath = "comp/listen.Main"
@@ -14,17 +15,36 @@ var componentPath core.ComponentPath = "comp/listen.Main"
 // Main is the component implementation for this package (`comp/listen.Main`).
 //
 // On requests with messages of type `comp/listen.Run`, it listens for new connections
-// and hands them to the `comp/conns.Main` component.
+// and hands them to the `comp/conns.Main` component.  If listening fails, it
+// asks the orchestrator to shut down.
+//
+// The listen address is configured with `{"address": "host:port"}`.  It cannot
+// be changed in place, so a configuration change restarts this component.
 var Main = core.ComponentImpl{
 	Path:         componentPath,
-	Dependencies: []core.ComponentPath{"comp/logger.Main", "comp/conns.Main"},
-	Start: func(orch *core.Orchestrator, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+	Dependencies: []core.ComponentPath{"comp/conns.Main"},
+	Timeouts: map[core.ComponentPath]time.Duration{
+		"comp/conns.Main": 5 * time.Second,
+	},
+	Requires:     []core.Service{logger.Service},
+	CheckConfig:  checkConfig,
+	Capabilities: []core.Capability{core.LifecycleCapability},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		lifecycle := host.Lifecycle()
+		config := Config{Address: "127.0.0.1:9000"}
+		if err := host.Config().Decode(&config); err != nil {
+			lifecycle.RequestShutdown(fmt.Errorf("%s: %w", componentPath, err))
+		}
 		l := &listen{
-			logger: logger.Wrap(deps),
-			conns:  deps["comp/conns.Main"],
-			ctx:    ctx,
-			done:   make(chan struct{}),
+			lifecycle: lifecycle,
+			config:    config,
+			logger:    logger.NewMainClient(host.Service(logger.Service)),
+			conns:     deps["comp/conns.Main"],
+			mailbox:   core.NewMailbox(core.MailboxConfig{Capacity: 1, Overflow: core.RejectOverflow}),
+			ctx:       ctx,
+			done:      make(chan struct{}),
 		}
+		go l.loop()
 		return l
 	},
 }
@@ -32,16 +52,39 @@ var Main = core.ComponentImpl{
 // Run is a core.Message that indicates the component should run
 type Run struct{}
 
+// Config is the configuration for this component
+type Config struct {
+	// Address is the TCP address on which to listen
+	Address string `json:"address"`
+}
+
+// checkConfig implements core.ComponentImpl#CheckConfig.
+func checkConfig(c core.Config) error {
+	config := Config{}
+	if err := c.Decode(&config); err != nil {
+		return err
+	}
+	if config.Address == "" {
+		return nil
+	}
+	_, _, err := net.SplitHostPort(config.Address)
+	return err
+}
+
 type listen struct {
 	core.BaseComponent
-	logger logger.Wrapper
-	conns  core.ComponentReference
-	ctx    context.Context
-	done   chan struct{}
+	lifecycle core.LifecycleController
+	config    Config
+	logger    logger.MainClient
+	conns     core.ComponentReference
+	mailbox   *core.Mailbox
+	ctx       context.Context
+	done      chan struct{}
 }
 
 var _ core.Component = &listen{}
 var _ core.ComponentReference = &listen{}
+var _ core.MailboxComponent = &listen{}
 
 // NewReference implements core.Component#NewReference.
 func (l *listen) NewReference() core.ComponentReference {
@@ -53,11 +96,31 @@ func (l *listen) Done() <-chan struct{} {
 	return l.done
 }
 
+// Mailbox implements core.MailboxComponent#Mailbox.
+func (l *listen) Mailbox() *core.Mailbox {
+	return l.mailbox
+}
+
+func (l *listen) loop() {
+	defer close(l.done)
+	for {
+		select {
+		case env := <-l.mailbox.C():
+			core.HandleEnvelope(env, l.Request)
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
 // Request implements core.ComponentReference#Request.
 func (l *listen) Request(ctx context.Context, msg core.Message) (core.Message, error) {
 	switch msg.(type) {
 	case Run:
 		err := l.run()
+		if err != nil {
+			l.lifecycle.RequestShutdown(fmt.Errorf("%s: %w", componentPath, err))
+		}
 		return nil, err
 	default:
 		return nil, fmt.Errorf("Unrecognized message type %T", msg)
@@ -66,12 +129,13 @@ func (l *listen) Request(ctx context.Context, msg core.Message) (core.Message, e
 
 // RequestAsync implements core.ComponentReference#RequestAsync.
 func (l *listen) RequestAsync(ctx context.Context, msg core.Message) {
-	go l.Request(ctx, msg)
+	if err := l.mailbox.Put(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
 }
 
 func (l *listen) run() error {
-	defer close(l.done)
-	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:9000")
+	addr, err := net.ResolveTCPAddr("tcp", l.config.Address)
 	if err != nil {
 		return err
 	}
@@ -81,7 +145,7 @@ func (l *listen) run() error {
 		return err
 	}
 
-	l.logger.Output(fmt.Sprintf("Listening on port %d", 9000))
+	l.logger.OutputAsync(context.Background(), logger.Output{Message: fmt.Sprintf("Listening on %s", listener.Addr())})
 
 	// stupid workaround to stop listening when the context expires
 	go func() {
@@ -92,6 +156,10 @@ func (l *listen) run() error {
 	for {
 		c, err := listener.Accept()
 		if err != nil {
+			if l.ctx.Err() != nil {
+				// the listener was closed on purpose
+				break
+			}
 			return err
 		}
 		_, err = l.conns.Request(l.ctx, conns.Connection{Conn: c})
@@ -100,6 +168,6 @@ func (l *listen) run() error {
 		}
 	}
 
-	l.logger.Output(fmt.Sprintf("Done on port %d", 9000))
+	l.logger.OutputAsync(context.Background(), logger.Output{Message: fmt.Sprintf("Done on %s", listener.Addr())})
 	return nil
 }
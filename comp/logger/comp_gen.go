@@ -0,0 +1,71 @@
+// Code generated by compgen; DO NOT EDIT.
+
+package logger
+
+import (
+	"comps/core"
+	"context"
+)
+
+var _ core.Component = &logger{}
+var _ core.ComponentReference = &logger{}
+var _ core.MailboxComponent = &logger{}
+
+// NewReference implements core.Component#NewReference.
+func (l *logger) NewReference() core.ComponentReference {
+	return l
+}
+
+// Request implements core.ComponentReference#Request.
+func (l *logger) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	switch v := msg.(type) {
+	case Output:
+		return nil, l.handleOutput(ctx, v)
+	default:
+		return l.handleOther(ctx, v)
+	}
+}
+
+// RequestAsync implements core.ComponentReference#RequestAsync.
+func (l *logger) RequestAsync(ctx context.Context, msg core.Message) {
+	if err := l.mailbox.Put(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+// Mailbox implements core.MailboxComponent#Mailbox.
+func (l *logger) Mailbox() *core.Mailbox {
+	return l.mailbox
+}
+
+// MainClient is a typed client for the Main component.
+type MainClient struct {
+	ref core.ComponentReference
+}
+
+// WrapMain returns a MainClient for the Main component, which must
+// be among the given dependencies.
+func WrapMain(deps map[core.ComponentPath]core.ComponentReference) MainClient {
+	return MainClient{ref: deps[componentPath]}
+}
+
+// NewMainClient returns a MainClient wrapping the given reference.
+func NewMainClient(ref core.ComponentReference) MainClient {
+	return MainClient{ref: ref}
+}
+
+// Reference returns the wrapped reference.
+func (c MainClient) Reference() core.ComponentReference {
+	return c.ref
+}
+
+// Output sends msg and waits for it to be handled.
+func (c MainClient) Output(ctx context.Context, msg Output) error {
+	_, err := c.ref.Request(ctx, msg)
+	return err
+}
+
+// OutputAsync sends msg without waiting.
+func (c MainClient) OutputAsync(ctx context.Context, msg Output) {
+	c.ref.RequestAsync(ctx, msg)
+}
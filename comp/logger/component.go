@@ -2,48 +2,131 @@ package logger
 
 import (
 	"comps/core"
+	"comps/core/clock"
 	"context"
 	"fmt"
+	"sync"
+	"time"
 )
 
+//go:generate go run comps/cmd/compgen
+
 var componentPath core.ComponentPath = "comp/logger.Main"
 
+// Service is the service provided by Main.  Other loggers providing it must
+// accept the `Output` message, and any fmt.Stringer.
+const Service core.Service = "logger"
+
 // Main is the component implementation for this package (`comp/logger.Main`).
 //
-// On requests with messages of type `comp/logger.Output`, it logs the message
-// and returns nil.
+// On requests with messages of type `comp/logger.Output`, it logs the message,
+// prefixed with the path of the component that sent it (see core.Caller), and
+// returns nil.  Any other message implementing fmt.Stringer is logged as
+// well, so the logger can subscribe to events on `core/comp/bus.Main`.
+// Asynchronous requests are queued in a mailbox, dropping the oldest messages
+// if the logger falls behind.
+//
+// Timestamps are enabled with the configuration `{"timestamps": true}`, and
+// can be changed in place.  If the configuration cannot be decoded at
+// startup, it asks the orchestrator to shut down.
 var Main = core.ComponentImpl{
 	Path:         componentPath,
 	Dependencies: []core.ComponentPath{},
-	Start: func(*core.Orchestrator, context.Context, map[core.ComponentPath]core.ComponentReference) core.Component {
-		return &logger{}
+	Provides:     []core.Service{Service},
+	CheckConfig: func(c core.Config) error {
+		return c.Decode(&Config{})
+	},
+	Capabilities: []core.Capability{core.LifecycleCapability},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		l := &logger{
+			clock:   host.Clock(),
+			mailbox: core.NewMailbox(core.MailboxConfig{Capacity: 100, Overflow: core.DropOldestOverflow}),
+			ctx:     ctx,
+			done:    make(chan struct{}),
+		}
+		if err := l.configure(host.Config()); err != nil {
+			host.Lifecycle().RequestShutdown(fmt.Errorf("%s: %w", componentPath, err))
+		}
+		go l.run()
+		return l
 	},
 }
 
+//comp:component Main mailbox default=handleOther
 type logger struct {
 	core.BaseComponent
+	clock   clock.Clock
+	mailbox *core.Mailbox
+	ctx     context.Context
+	done    chan struct{}
+
+	// mu protects config
+	mu     sync.Mutex
+	config Config
+}
+
+// Config is the configuration for this component
+type Config struct {
+	// Timestamps prefixes each line with the time it was logged
+	Timestamps bool `json:"timestamps"`
+}
+
+// Done implements core.Component#Done.
+func (l *logger) Done() <-chan struct{} {
+	return l.done
 }
 
-var _ core.Component = &logger{}
-var _ core.ComponentReference = &logger{}
+func (l *logger) run() {
+	defer close(l.done)
+	for {
+		select {
+		case env := <-l.mailbox.C():
+			core.HandleEnvelope(env, l.Request)
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
 
-// NewReference implements core.Component#NewReference.
-func (l *logger) NewReference() core.ComponentReference {
-	return l
+func (l *logger) handleOutput(ctx context.Context, msg Output) error {
+	if caller, ok := core.Caller(ctx); ok {
+		l.println(fmt.Sprintf("%s: %s", caller, msg.Message))
+	} else {
+		l.println(msg.Message)
+	}
+	return nil
 }
 
-// Request implements core.ComponentReference#Request.
-func (l *logger) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+func (l *logger) handleOther(ctx context.Context, msg core.Message) (core.Message, error) {
 	switch v := msg.(type) {
-	case Output:
-		fmt.Println(v.Message)
+	case core.Reconfigure:
+		return nil, l.configure(v.Config)
+	case fmt.Stringer:
+		l.println(v.String())
 		return nil, nil
-	default:
-		return nil, fmt.Errorf("Unrecognized message type %T", msg)
 	}
+	return nil, fmt.Errorf("Unrecognized message type %T", msg)
 }
 
-// RequestAsync implements core.ComponentReference#RequestAsync.
-func (l *logger) RequestAsync(ctx context.Context, msg core.Message) {
-	l.Request(ctx, msg)
+func (l *logger) configure(c core.Config) error {
+	config := Config{}
+	if err := c.Decode(&config); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+	return nil
+}
+
+func (l *logger) println(line string) {
+	l.mu.Lock()
+	timestamps := l.config.Timestamps
+	l.mu.Unlock()
+
+	if timestamps {
+		line = l.clock.Now().Format(time.RFC3339) + " " + line
+	}
+	fmt.Println(line)
 }
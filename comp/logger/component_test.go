@@ -0,0 +1,32 @@
+package logger_test
+
+import (
+	"comps/comp/logger"
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBadConfigRequestsShutdown(t *testing.T) {
+	orch := core.NewOrchestrator(logger.Main)
+	orch.SetConfigSource(func() (map[core.ComponentPath]core.Config, error) {
+		return map[core.ComponentPath]core.Config{logger.Main.Path: core.Config(`{"timestamps": "yes"}`)}, nil
+	})
+	orch.SetStopTimeout(comptest.DefaultTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	status := make(chan int, 1)
+	go func() { status <- orch.Run(ctx) }()
+
+	select {
+	case got := <-status:
+		if got != core.ExitFailure {
+			t.Fatalf("exit status %d, want %d", got, core.ExitFailure)
+		}
+	case <-time.After(comptest.DefaultTimeout):
+		t.Fatal("the orchestrator did not shut down")
+	}
+}
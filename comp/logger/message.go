@@ -1,6 +1,8 @@
 package logger
 
 // Output is a core.Message containing the string to be logged.
+//
+//comp:message Main
 type Output struct {
 	Message string
 }
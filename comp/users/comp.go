@@ -1,12 +1,14 @@
 package users
 
 import (
-	"comps/comp/logger"
 	"comps/core"
+	"comps/core/comp/bus"
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 )
 
 var componentPath core.ComponentPath = "comp/users.Main"
@@ -16,33 +18,134 @@ var componentPath core.ComponentPath = "comp/users.Main"
 // This component accepts NewUser, UserGone, and UserMessage messages to handle
 // user traffic.  It accepts a SetConnsComponent message at startup to identify
 // the `comp/conns.Main` component, on which it has a weak dependency.
+//
+// Room membership changes are published on `core/comp/bus.Main` as Joined
+// messages (topic `users.joined`) and Left messages (topic `users.left`).
+//
+// A user can take a name with `/nick <name>`.  The rooms of named users are
+// preserved across restarts with a snapshot, keyed by name, since connection
+// IDs are reused after `comp/conns.Main` restarts: a user who takes the same
+// name again is returned to their room.  When the component is replaced (see
+// core.Orchestrator#Replace), connected users, with their rooms, are handed to
+// the new instance.
 var Main = core.ComponentImpl{
 	Path:         componentPath,
-	Dependencies: []core.ComponentPath{"comp/logger.Main"},
-	Start: func(orch *core.Orchestrator, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+	Dependencies: []core.ComponentPath{"core/comp/bus.Main"},
+	Timeouts:     map[core.ComponentPath]time.Duration{"core/comp/bus.Main": time.Second},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
 		c := &component{
-			logger: logger.Wrap(deps),
-			users:  map[int]*user{},
+			bus:     deps["core/comp/bus.Main"],
+			users:   map[int]*user{},
+			rooms:   map[string]string{},
+			mailbox: core.NewMailbox(core.MailboxConfig{Capacity: 10, Overflow: core.BlockOverflow}),
+			ctx:     ctx,
+			done:    make(chan struct{}),
+		}
+		if users, ok := host.Handoff().(map[int]*user); ok {
+			// replacing a running instance; take over its connected users
+			c.users = users
+		} else if snapshot := host.Snapshot(); snapshot != nil {
+			if err := json.Unmarshal(snapshot, &c.rooms); err != nil {
+				// an unreadable snapshot only loses room membership
+				c.rooms = map[string]string{}
+			}
 		}
+		go c.run()
 		return c
 	},
 }
 
 type component struct {
 	core.BaseComponent
-	mu     sync.Mutex
-	logger logger.Wrapper
-	users  map[int]*user
+	mu    sync.Mutex
+	bus   core.ComponentReference
+	users map[int]*user
+
+	// rooms contains the rooms of named users restored from a snapshot, who
+	// have not yet taken their name again
+	rooms map[string]string
+
+	mailbox *core.Mailbox
+	ctx     context.Context
+	done    chan struct{}
 }
 
 var _ core.Component = &component{}
 var _ core.ComponentReference = &component{}
+var _ core.MailboxComponent = &component{}
+var _ core.Snapshotter = &component{}
+var _ core.HandoffComponent = &component{}
+var _ core.StatsComponent = &component{}
 
 // NewReference implements core.Component#NewReference.
 func (c *component) NewReference() core.ComponentReference {
 	return c
 }
 
+// Done implements core.Component#Done.
+func (c *component) Done() <-chan struct{} {
+	return c.done
+}
+
+// Mailbox implements core.MailboxComponent#Mailbox.
+func (c *component) Mailbox() *core.Mailbox {
+	return c.mailbox
+}
+
+func (c *component) run() {
+	defer close(c.done)
+	for {
+		select {
+		case env := <-c.mailbox.C():
+			core.HandleEnvelope(env, c.Request)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// Stats implements core.StatsComponent#Stats.
+func (c *component) Stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rooms := map[string]struct{}{}
+	for _, u := range c.users {
+		if u.room != "" {
+			rooms[u.room] = struct{}{}
+		}
+	}
+	return map[string]interface{}{
+		"users": len(c.users),
+		"rooms": len(rooms),
+	}
+}
+
+// Snapshot implements core.Snapshotter#Snapshot.
+func (c *component) Snapshot() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rooms := map[string]string{}
+	for name, room := range c.rooms {
+		rooms[name] = room
+	}
+	for _, u := range c.users {
+		if u.name != "" && u.room != "" {
+			rooms[u.name] = u.room
+		}
+	}
+	return json.Marshal(rooms)
+}
+
+// Handoff implements core.HandoffComponent#Handoff.
+func (c *component) Handoff() interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.users
+}
+
 // Request implements core.ComponentReference#Request.
 func (c *component) Request(ctx context.Context, msg core.Message) (core.Message, error) {
 	c.mu.Lock()
@@ -62,13 +165,31 @@ func (c *component) Request(ctx context.Context, msg core.Message) (core.Message
 		}
 		switch {
 		case strings.HasPrefix(v.Message, "/join"):
-			room := v.Message[6:]
-			if u.room != "" {
-				c.sendToRoom(0, u.room, fmt.Sprintf("%d has left %s", v.Cid, room))
+			room := strings.TrimSpace(strings.TrimPrefix(v.Message, "/join"))
+			if room == "" {
+				u.sendMessage("usage: /join <room>")
+				break
+			}
+			c.join(u, room)
+		case strings.HasPrefix(v.Message, "/nick"):
+			name := strings.TrimSpace(strings.TrimPrefix(v.Message, "/nick"))
+			if name == "" {
+				u.sendMessage("usage: /nick <name>")
+				break
+			}
+			if c.named(name) {
+				u.sendMessage(fmt.Sprintf("%s is taken", name))
+				break
+			}
+			u.name = name
+			u.sendMessage(fmt.Sprintf("you are %s", name))
+			if room, found := c.rooms[name]; found {
+				delete(c.rooms, name)
+				if u.room == "" {
+					c.join(u, room)
+					u.sendMessage(fmt.Sprintf("welcome back to %s!", room))
+				}
 			}
-			u.room = room
-			c.sendToRoom(0, u.room, fmt.Sprintf("%d has joined %s", v.Cid, room))
-			c.logger.Output(fmt.Sprintf("%d has joined %s", v.Cid, room))
 		default:
 			if u.room == "" {
 				u.sendMessage("join a room first (/join)")
@@ -83,6 +204,28 @@ func (c *component) Request(ctx context.Context, msg core.Message) (core.Message
 	return nil, nil
 }
 
+// join moves a user to the given room.  This assumes that c.mu is held.
+func (c *component) join(u *user, room string) {
+	if u.room != "" {
+		c.sendToRoom(0, u.room, fmt.Sprintf("%d has left %s", u.cid, u.room))
+		c.bus.RequestAsync(c.ctx, bus.Publish{Topic: "users.left", Message: Left{Cid: u.cid, Room: u.room}})
+	}
+	u.room = room
+	c.sendToRoom(0, u.room, fmt.Sprintf("%d has joined %s", u.cid, room))
+	c.bus.RequestAsync(c.ctx, bus.Publish{Topic: "users.joined", Message: Joined{Cid: u.cid, Room: room}})
+}
+
+// named determines whether a connected user has the given name.  This assumes
+// that c.mu is held.
+func (c *component) named(name string) bool {
+	for _, u := range c.users {
+		if u.name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *component) sendToRoom(senderCid int, room string, message string) {
 	for cid, u := range c.users {
 		if cid != senderCid && u.room == room {
@@ -93,5 +236,7 @@ func (c *component) sendToRoom(senderCid int, room string, message string) {
 
 // RequestAsync implements core.ComponentReference#RequestAsync.
 func (c *component) RequestAsync(ctx context.Context, msg core.Message) {
-	c.Request(ctx, msg)
+	if err := c.mailbox.Put(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
 }
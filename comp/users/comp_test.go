@@ -0,0 +1,108 @@
+package users_test
+
+import (
+	"comps/comp/users"
+	"comps/core"
+	"comps/core/comp/bus"
+	"comps/core/comptest"
+	"context"
+	"sync"
+	"testing"
+)
+
+// inbox collects the messages sent to a user.
+type inbox struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (in *inbox) send(msg string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.msgs = append(in.msgs, msg)
+}
+
+func (in *inbox) last() string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if len(in.msgs) == 0 {
+		return ""
+	}
+	return in.msgs[len(in.msgs)-1]
+}
+
+// start starts users.Main, as the root, with snapshots in dir.
+func start(t *testing.T, dir string) *core.Orchestrator {
+	t.Helper()
+
+	orch := core.NewOrchestrator(users.Main, bus.Main)
+	orch.SetSnapshotStore(core.DirSnapshotStore(dir))
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return orch
+}
+
+func stop(t *testing.T, orch *core.Orchestrator) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), comptest.DefaultTimeout)
+	defer cancel()
+	if err := orch.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %s", err)
+	}
+}
+
+func request(t *testing.T, orch *core.Orchestrator, msg core.Message) {
+	t.Helper()
+
+	if _, err := orch.Root.Request(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoomsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	orch := start(t, dir)
+	request(t, orch, users.NewUser{Cid: 1, SendMessage: (&inbox{}).send})
+	request(t, orch, users.UserMessage{Cid: 1, Message: "/nick alice"})
+	request(t, orch, users.UserMessage{Cid: 1, Message: "/join lobby"})
+	request(t, orch, users.NewUser{Cid: 2, SendMessage: (&inbox{}).send})
+	request(t, orch, users.UserMessage{Cid: 2, Message: "/join lobby"})
+	stop(t, orch)
+
+	orch = start(t, dir)
+	defer stop(t, orch)
+
+	// connection IDs are reused, so an unnamed user is not restored
+	stranger := &inbox{}
+	request(t, orch, users.NewUser{Cid: 2, SendMessage: stranger.send})
+	request(t, orch, users.UserMessage{Cid: 2, Message: "hello"})
+	if got, want := stranger.last(), "join a room first (/join)"; got != want {
+		t.Errorf("unnamed user got %q, want %q", got, want)
+	}
+
+	alice := &inbox{}
+	request(t, orch, users.NewUser{Cid: 1, SendMessage: alice.send})
+	request(t, orch, users.UserMessage{Cid: 1, Message: "/nick alice"})
+	if got, want := alice.last(), "welcome back to lobby!"; got != want {
+		t.Errorf("alice got %q, want %q", got, want)
+	}
+}
+
+func TestNickTaken(t *testing.T) {
+	orch := start(t, t.TempDir())
+	defer stop(t, orch)
+
+	second := &inbox{}
+	request(t, orch, users.NewUser{Cid: 1, SendMessage: (&inbox{}).send})
+	request(t, orch, users.UserMessage{Cid: 1, Message: "/nick alice"})
+	request(t, orch, users.NewUser{Cid: 2, SendMessage: second.send})
+	request(t, orch, users.UserMessage{Cid: 2, Message: "/nick alice"})
+	if got, want := second.last(), "alice is taken"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
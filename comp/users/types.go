@@ -1,5 +1,7 @@
 package users
 
+import "fmt"
+
 // NewUser indicates that the given user has connected.
 type NewUser struct {
 	// Cid is the user's connection ID
@@ -24,8 +26,33 @@ type UserMessage struct {
 	Message string
 }
 
+// Joined is published on the bus when a user joins a room.
+type Joined struct {
+	// Cid is the user's connection ID
+	Cid int
+	// Room is the room the user joined
+	Room string
+}
+
+func (j Joined) String() string {
+	return fmt.Sprintf("%d has joined %s", j.Cid, j.Room)
+}
+
+// Left is published on the bus when a user leaves a room.
+type Left struct {
+	// Cid is the user's connection ID
+	Cid int
+	// Room is the room the user left
+	Room string
+}
+
+func (l Left) String() string {
+	return fmt.Sprintf("%d has left %s", l.Cid, l.Room)
+}
+
 type user struct {
 	cid         int
+	name        string
 	room        string
 	sendMessage func(string)
 }
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"expvar"
+	"fmt"
+	"reflect"
+)
+
+// AccessPolicy restricts the messages that other components may send to a
+// component through the references the orchestrator gives them.  A nil
+// policy allows everything; otherwise, a message is allowed only if a rule
+// for its type allows the caller.
+//
+// Requests made directly to a component, such as Reconfigure messages from
+// the orchestrator, are not subject to the policy.
+type AccessPolicy []AccessRule
+
+// AccessRule allows some or all callers to send messages of one type.
+type AccessRule struct {
+	// Message is a value of the message type to which the rule applies, e.g.,
+	// `Serve{}`.  Only its type is significant.
+	Message Message
+
+	// Callers lists the components allowed to send the message.  If this is
+	// nil, any component may send it.
+	Callers []ComponentPath
+}
+
+// allows determines whether the policy allows caller to send msg.
+func (p AccessPolicy) allows(caller ComponentPath, msg Message) bool {
+	if p == nil {
+		return true
+	}
+	typ := reflect.TypeOf(msg)
+	for _, rule := range p {
+		if reflect.TypeOf(rule.Message) != typ {
+			continue
+		}
+		if rule.Callers == nil {
+			return true
+		}
+		for _, c := range rule.Callers {
+			if c == caller {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AccessDeniedError is returned for requests rejected by the target's
+// AccessPolicy.  Each denial is also counted in the target's status and in the
+// `comps.accessDenied` expvar, and published as a LifecycleEvent.
+type AccessDeniedError struct {
+	// Caller is the component that sent the message.
+	Caller ComponentPath
+
+	// Target is the component to which the message was sent.
+	Target ComponentPath
+
+	// MessageType is the type of the message, e.g., `debug.Serve`.
+	MessageType string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("Access denied: %s may not send %s to %s", e.Caller, e.MessageType, e.Target)
+}
+
+// accessDenied counts requests rejected by access policies, keyed by target
+// and then by caller.  This is published with expvar (see
+// `core/comp/debug.Expvar`).
+var accessDenied = expvar.NewMap("comps.accessDenied")
+
+// checkAccess applies the target's access policy to a request, recording and
+// returning an *AccessDeniedError if it is not allowed.
+func (orch *Orchestrator) checkAccess(policy AccessPolicy, caller, target ComponentPath, msg Message) error {
+	if policy.allows(caller, msg) {
+		return nil
+	}
+	err := &AccessDeniedError{
+		Caller:      caller,
+		Target:      target,
+		MessageType: fmt.Sprintf("%T", msg),
+	}
+
+	orch.historyMu.Lock()
+	orch.historyFor(target).denied++
+	callers, ok := accessDenied.Get(string(target)).(*expvar.Map)
+	if !ok {
+		callers = new(expvar.Map)
+		accessDenied.Set(string(target), callers)
+	}
+	callers.Add(string(caller), 1)
+	orch.historyMu.Unlock()
+
+	orch.lifecycleEvent(LifecycleEvent{
+		Kind:       AccessDeniedEvent,
+		Component:  caller,
+		Dependency: target,
+		Message:    err.MessageType,
+	})
+	return err
+}
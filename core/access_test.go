@@ -0,0 +1,103 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// serve is a message restricted by an access policy.
+type serve struct{}
+
+func TestAccessPolicy(t *testing.T) {
+	svc := comptest.NewFakeReference()
+	refs := map[core.ComponentPath]core.ComponentReference{}
+	keep := func(caller core.ComponentPath) func(context.Context, map[core.ComponentPath]core.ComponentReference) {
+		return func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+			refs[caller] = deps["svc"]
+		}
+	}
+	svcImpl := fakeImpl("svc", svc, nil, nil)
+	svcImpl.Access = core.AccessPolicy{
+		{Message: serve{}, Callers: []core.ComponentPath{"admin"}},
+		{Message: ""},
+	}
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"admin", "svc"}, keep("root")),
+		fakeImpl("admin", comptest.NewFakeReference(), []core.ComponentPath{"svc"}, keep("admin")),
+		svcImpl,
+	)
+	sink := &eventSink{events: make(chan core.LifecycleEvent, 10)}
+	orch.HandleLifecycleEvents(sink)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	for _, tc := range []struct {
+		caller  core.ComponentPath
+		msg     core.Message
+		allowed bool
+	}{
+		{"admin", serve{}, true},
+		{"root", serve{}, false},
+		{"root", "anyone may send strings", true},
+		{"admin", 42, false},
+	} {
+		_, err := refs[tc.caller].Request(context.Background(), tc.msg)
+		var denied *core.AccessDeniedError
+		if tc.allowed && err != nil {
+			t.Errorf("%s sending %T: %v", tc.caller, tc.msg, err)
+		}
+		if !tc.allowed && (!errors.As(err, &denied) || denied.Caller != tc.caller || denied.Target != "svc") {
+			t.Errorf("%s sending %T: got %v, want an *AccessDeniedError", tc.caller, tc.msg, err)
+		}
+	}
+
+	if msgs := svc.Messages(); len(msgs) != 2 {
+		t.Errorf("svc received %v, want only the allowed messages", msgs)
+	}
+	if n := orch.Status()["svc"].Denied; n != 2 {
+		t.Errorf("%d denials in status, want 2", n)
+	}
+	ev := <-sink.events
+	if ev.Kind != core.AccessDeniedEvent || ev.Component != "root" || ev.Dependency != "svc" || ev.State != "" || ev.Message != "core_test.serve" {
+		t.Errorf("event %+v", ev)
+	}
+	if got, want := ev.String(), "access-denied root -> svc: core_test.serve"; got != want {
+		t.Errorf("event string %q, want %q", got, want)
+	}
+}
+
+func TestAccessDeniedAsync(t *testing.T) {
+	var ref core.ComponentReference
+	svcImpl := fakeImpl("svc", comptest.NewFakeReference(), nil, nil)
+	svcImpl.Access = core.AccessPolicy{}
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				ref = deps["svc"]
+			}),
+		svcImpl,
+	)
+	sink := &letterSink{letters: make(chan core.DeadLetter, 1)}
+	orch.HandleDeadLetters(sink)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	ref.RequestAsync(context.Background(), "denied")
+	select {
+	case letter := <-sink.letters:
+		var denied *core.AccessDeniedError
+		if !errors.As(letter.Err, &denied) {
+			t.Fatalf("dead letter for %v", letter.Err)
+		}
+	case <-time.After(comptest.DefaultTimeout):
+		t.Fatal("no dead letter for a denied async request")
+	}
+}
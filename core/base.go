@@ -35,5 +35,6 @@ func (bcr *BaseComponentReference) Request(context.Context, Message) (Message, e
 }
 
 // Request implements ComponentReference#RequestAsync.
-func (bcr *BaseComponentReference) RequestAsync(context.Context, Message) {
+func (bcr *BaseComponentReference) RequestAsync(ctx context.Context, msg Message) {
+	ReportAsyncFailure(ctx, fmt.Errorf("%T does not accept requests", bcr))
 }
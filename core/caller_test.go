@@ -0,0 +1,37 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"testing"
+)
+
+func TestCallerStampedOnRequests(t *testing.T) {
+	root, svc := comptest.NewFakeReference(), comptest.NewFakeReference()
+	var ref core.ComponentReference
+	orch := core.NewOrchestrator(
+		fakeImpl("root", root, []core.ComponentPath{"svc"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				ref = deps["svc"]
+			}),
+		fakeImpl("svc", svc, nil, nil),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	ref.Request(context.Background(), "sync")
+	ref.RequestAsync(context.Background(), "async")
+	orch.Root.Request(context.Background(), "from outside")
+
+	for _, r := range svc.Received() {
+		if caller, ok := core.Caller(r.Ctx); caller != "root" || !ok {
+			t.Errorf("%v: caller %q, %v; want root", r.Msg, caller, ok)
+		}
+	}
+	if caller, ok := core.Caller(root.Received()[0].Ctx); ok {
+		t.Errorf("caller %q for a request through Root", caller)
+	}
+}
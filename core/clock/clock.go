@@ -0,0 +1,113 @@
+// Package clock provides an abstraction over the passage of time, so that
+// timing-dependent behavior can be tested deterministically.
+//
+// The orchestrator hands a Clock to components (see
+// core.Orchestrator#Clock); components should use it instead of the functions
+// in the time package.
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time
+	// on the returned channel.
+	After(d time.Duration) <-chan time.Time
+
+	// Sleep pauses the current goroutine for at least the duration d.
+	Sleep(d time.Duration)
+
+	// NewTimer creates a new Timer that will send the current time on its
+	// channel after at least duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is analogous to time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing, returning false if the timer has
+	// already expired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to expire after duration d, returning true if
+	// the timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// Real returns a Clock based on the time package.
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (rt realTimer) C() <-chan time.Time        { return rt.t.C }
+func (rt realTimer) Stop() bool                 { return rt.t.Stop() }
+func (rt realTimer) Reset(d time.Duration) bool { return rt.t.Reset(d) }
+
+// WithTimeout is analogous to context.WithTimeout, but measures the timeout
+// with the given clock.  When the timeout expires, the context's Err method
+// returns context.DeadlineExceeded.
+func WithTimeout(parent context.Context, clk Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := clk.(realClock); ok {
+		return context.WithTimeout(parent, d)
+	}
+
+	inner, cancel := context.WithCancel(parent)
+	ctx := &timeoutCtx{Context: inner, deadline: clk.Now().Add(d)}
+	timer := clk.NewTimer(d)
+	go func() {
+		select {
+		case <-timer.C():
+			ctx.mu.Lock()
+			ctx.err = context.DeadlineExceeded
+			ctx.mu.Unlock()
+			cancel()
+		case <-inner.Done():
+			timer.Stop()
+		}
+	}()
+	return ctx, cancel
+}
+
+// timeoutCtx is a context that was cancelled by a clock timer
+type timeoutCtx struct {
+	context.Context
+	deadline time.Time
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *timeoutCtx) Deadline() (time.Time, bool) {
+	if parent, ok := c.Context.Deadline(); ok && parent.Before(c.deadline) {
+		return parent, true
+	}
+	return c.deadline, true
+}
+
+func (c *timeoutCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	return c.Context.Err()
+}
@@ -0,0 +1,158 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to, for use in tests.  Timers
+// fire, in order, when Advance or Set moves the time past their deadlines.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{}
+}
+
+var _ Clock = &Fake{}
+
+// NewFake creates a new Fake clock set to the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+// Now implements Clock#Now.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// After implements Clock#After.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// Sleep implements Clock#Sleep.  It blocks until the fake time has been
+// advanced by at least d.
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+// NewTimer implements Clock#NewTimer.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	f.schedule(t, d)
+	return t
+}
+
+// Advance moves the time forward by d, firing any timers that expire.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the time to the given value, firing any timers that expire.  Time
+// never moves backward; setting an earlier time does nothing.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if now.Before(f.now) {
+		return
+	}
+	f.now = now
+	for len(f.timers) > 0 && !f.timers[0].deadline.After(now) {
+		t := f.timers[0]
+		f.timers = f.timers[1:]
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+	f.notify()
+}
+
+// Waiters returns the number of timers currently waiting to fire.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.timers)
+}
+
+// BlockUntil blocks until at least n timers are waiting to fire.  This is
+// useful to ensure that a goroutine under test has started sleeping before
+// advancing the clock.
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		count := len(f.timers)
+		changed := f.changed
+		f.mu.Unlock()
+
+		if count >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// schedule adds the timer to the list of timers.  This assumes f.mu is held.
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	t.deadline = f.now.Add(d)
+	f.timers = append(f.timers, t)
+	sort.SliceStable(f.timers, func(i, j int) bool {
+		return f.timers[i].deadline.Before(f.timers[j].deadline)
+	})
+	f.notify()
+}
+
+// unschedule removes the timer from the list of timers, returning true if it
+// was there.  This assumes f.mu is held.
+func (f *Fake) unschedule(t *fakeTimer) bool {
+	for i, other := range f.timers {
+		if other == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			f.notify()
+			return true
+		}
+	}
+	return false
+}
+
+// notify wakes anything in BlockUntil.  This assumes f.mu is held.
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+type fakeTimer struct {
+	clock    *Fake
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	return t.clock.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasActive := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+	return wasActive
+}
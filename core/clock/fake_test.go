@@ -0,0 +1,126 @@
+package clock_test
+
+import (
+	"comps/core/clock"
+	"context"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// fired returns the time sent on c, if any, without waiting.
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeTimersFireWhenAdvancedPastDeadline(t *testing.T) {
+	clk := clock.NewFake(epoch)
+	late := clk.After(2 * time.Second)
+	early := clk.After(time.Second)
+
+	clk.Advance(999 * time.Millisecond)
+	if _, ok := fired(early); ok {
+		t.Fatal("timer fired before its deadline")
+	}
+
+	clk.Advance(time.Millisecond)
+	if got, ok := fired(early); !ok || !got.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("early timer: got %v, %v; want %v", got, ok, epoch.Add(time.Second))
+	}
+	if _, ok := fired(late); ok {
+		t.Fatal("late timer fired early")
+	}
+	if n := clk.Waiters(); n != 1 {
+		t.Fatalf("%d waiters, want 1", n)
+	}
+
+	clk.Advance(time.Hour)
+	if got, ok := fired(late); !ok || !got.Equal(epoch.Add(time.Hour+time.Second)) {
+		t.Fatalf("late timer: got %v, %v", got, ok)
+	}
+}
+
+func TestFakeSetNeverMovesBackward(t *testing.T) {
+	clk := clock.NewFake(epoch)
+	clk.Set(epoch.Add(-time.Hour))
+	if now := clk.Now(); !now.Equal(epoch) {
+		t.Fatalf("Now is %v after setting an earlier time, want %v", now, epoch)
+	}
+}
+
+func TestFakeTimerStopAndReset(t *testing.T) {
+	clk := clock.NewFake(epoch)
+	timer := clk.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("Stop returned false for an active timer")
+	}
+	if timer.Stop() {
+		t.Fatal("Stop returned true for a stopped timer")
+	}
+	clk.Advance(time.Minute)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("stopped timer fired")
+	}
+
+	if timer.Reset(time.Second) {
+		t.Fatal("Reset returned true for a stopped timer")
+	}
+	if !timer.Reset(2 * time.Second) {
+		t.Fatal("Reset returned false for an active timer")
+	}
+	clk.Advance(time.Second)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("reset timer fired at its original deadline")
+	}
+	clk.Advance(time.Second)
+	if _, ok := fired(timer.C()); !ok {
+		t.Fatal("reset timer did not fire at its new deadline")
+	}
+}
+
+func TestFakeBlockUntilAndSleep(t *testing.T) {
+	clk := clock.NewFake(epoch)
+	woke := make(chan struct{})
+	go func() {
+		clk.Sleep(time.Minute)
+		close(woke)
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+	select {
+	case <-woke:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Sleep did not return after the clock advanced")
+	}
+}
+
+func TestWithTimeoutUsesFakeClock(t *testing.T) {
+	clk := clock.NewFake(epoch)
+	ctx, cancel := clock.WithTimeout(context.Background(), clk, time.Second)
+	defer cancel()
+
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("Deadline is %v, %v; want %v", deadline, ok, epoch.Add(time.Second))
+	}
+	clk.BlockUntil(1)
+	if ctx.Err() != nil {
+		t.Fatalf("context expired before the clock advanced: %s", ctx.Err())
+	}
+
+	clk.Advance(time.Second)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not done after the clock advanced past its deadline")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("Err is %v, want context.DeadlineExceeded", ctx.Err())
+	}
+}
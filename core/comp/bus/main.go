@@ -0,0 +1,290 @@
+package bus
+
+import (
+	"comps/core"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var componentPath core.ComponentPath = "core/comp/bus.Main"
+
+// Main is the component implementation for this package (`core/comp/bus.Main`).
+//
+// This component delivers published messages to subscribers, so that
+// publishers need not know which components are interested in their
+// messages.  Send a `Subscribe` message to subscribe a reference to a topic,
+// and a `Publish` message to publish on a topic.
+//
+// Topics are dot-separated, e.g., `users.joined`.  In a subscription, a `*`
+// segment matches any single segment, and a final `**` segment matches any
+// number of remaining segments (including none).
+//
+// Each subscriber has its own mailbox, so a slow subscriber does not delay
+// delivery to others.  Messages are delivered with Request, in the order in
+// which they were published.  Deliveries carry the values of the publisher's
+// context, but not its cancellation: they are cancelled only when the
+// subscription ends, or the bus stops.  Once the bus has stopped, publishing
+// fails, and publishers blocked on a full mailbox are released.
+//
+// This component also registers itself with the orchestrator to receive
+// `core.LifecycleEvent` messages, and publishes each on the event's topic
+// (`lifecycle.<kind>`).
+var Main = core.ComponentImpl{
+	Path:         componentPath,
+	Dependencies: []core.ComponentPath{},
+	Capabilities: []core.Capability{core.SinkCapability},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		b := &bus{
+			subscriptions: map[int]*subscription{},
+			ctx:           ctx,
+			done:          make(chan struct{}),
+		}
+		go func() {
+			<-ctx.Done()
+			b.wg.Wait()
+			close(b.done)
+		}()
+		host.Sinks().HandleLifecycleEvents(b)
+		return b
+	},
+}
+
+// defaultBuffer is the mailbox capacity for subscriptions that do not specify
+// one
+const defaultBuffer = 10
+
+type bus struct {
+	core.BaseComponent
+	mu            sync.Mutex
+	nextID        int
+	subscriptions map[int]*subscription
+	published     int
+
+	// wg tracks running subscription goroutines
+	wg   sync.WaitGroup
+	ctx  context.Context
+	done chan struct{}
+}
+
+type subscription struct {
+	id      int
+	pattern []string
+	sub     Subscribe
+	mailbox *core.Mailbox
+
+	// ctx is cancelled when the subscription ends, or the bus stops
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var _ core.Component = &bus{}
+var _ core.ComponentReference = &bus{}
+var _ core.StatsComponent = &bus{}
+
+// NewReference implements core.Component#NewReference.
+func (b *bus) NewReference() core.ComponentReference {
+	return b
+}
+
+// Done implements core.Component#Done.
+func (b *bus) Done() <-chan struct{} {
+	return b.done
+}
+
+// Stats implements core.StatsComponent#Stats.
+func (b *bus) Stats() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return map[string]interface{}{
+		"subscriptions": len(b.subscriptions),
+		"published":     b.published,
+	}
+}
+
+// Request implements core.ComponentReference#Request.
+func (b *bus) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	switch v := msg.(type) {
+	case Subscribe:
+		id, err := b.subscribe(v)
+		if err != nil {
+			return nil, err
+		}
+		return Subscribed{ID: id}, nil
+	case Unsubscribe:
+		b.unsubscribe(v.ID)
+		return nil, nil
+	case Publish:
+		return nil, b.publish(ctx, v)
+	case core.LifecycleEvent:
+		return nil, b.publish(ctx, Publish{Topic: v.Topic(), Message: v})
+	default:
+		return nil, fmt.Errorf("Unrecognized message type %T", msg)
+	}
+}
+
+// RequestAsync implements core.ComponentReference#RequestAsync.
+func (b *bus) RequestAsync(ctx context.Context, msg core.Message) {
+	if _, err := b.Request(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+func (b *bus) subscribe(v Subscribe) (int, error) {
+	if v.Topic == "" {
+		return 0, fmt.Errorf("Subscribe requires a topic")
+	}
+	if v.Subscriber == nil {
+		return 0, fmt.Errorf("Subscribe requires a subscriber")
+	}
+	pattern := strings.Split(v.Topic, ".")
+	for i, seg := range pattern {
+		if seg == "**" && i != len(pattern)-1 {
+			return 0, fmt.Errorf("'**' may only appear at the end of topic %q", v.Topic)
+		}
+	}
+
+	buffer := v.Buffer
+	if buffer == 0 {
+		buffer = defaultBuffer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ctx.Err() != nil {
+		return 0, fmt.Errorf("%s is stopping", componentPath)
+	}
+
+	b.nextID++
+	ctx, cancel := context.WithCancel(b.ctx)
+	s := &subscription{
+		id:      b.nextID,
+		pattern: pattern,
+		sub:     v,
+		mailbox: core.NewMailbox(core.MailboxConfig{Capacity: buffer, Overflow: v.Overflow}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	b.subscriptions[s.id] = s
+
+	b.wg.Add(1)
+	go b.deliver(s)
+
+	return s.id, nil
+}
+
+func (b *bus) unsubscribe(id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, found := b.subscriptions[id]
+	if found {
+		delete(b.subscriptions, id)
+		s.cancel()
+	}
+}
+
+func (b *bus) publish(ctx context.Context, v Publish) error {
+	topic := strings.Split(v.Topic, ".")
+	for _, seg := range topic {
+		if seg == "*" || seg == "**" {
+			return fmt.Errorf("Cannot publish on topic %q, which contains wildcards", v.Topic)
+		}
+	}
+
+	b.mu.Lock()
+	if b.ctx.Err() != nil {
+		b.mu.Unlock()
+		return fmt.Errorf("%s is stopping", componentPath)
+	}
+	b.published++
+	matching := []*subscription{}
+	for _, s := range b.subscriptions {
+		if !matches(s.pattern, topic) {
+			continue
+		}
+		if s.sub.Filter != nil && !s.sub.Filter(v.Topic, v.Message) {
+			continue
+		}
+		matching = append(matching, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range matching {
+		if err := b.put(ctx, s, v.Message); err != nil && s.ctx.Err() == nil {
+			core.ReportAsyncFailure(ctx, err)
+		}
+	}
+	return nil
+}
+
+// put adds a message to a subscription's mailbox, blocking (depending on its
+// overflow policy) until the publisher's context is cancelled, or the
+// subscription ends.
+func (b *bus) put(ctx context.Context, s *subscription, msg core.Message) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(s.ctx, cancel)()
+
+	return s.mailbox.Put(ctx, msg)
+}
+
+// deliver runs in a goroutine for each subscription, delivering messages from
+// its mailbox until the subscription ends.
+func (b *bus) deliver(s *subscription) {
+	defer b.wg.Done()
+	defer s.cancel()
+
+	// a nil channel blocks forever, so this is safe if Until is not set, or
+	// the subscriber has no Done channel
+	until := s.sub.Until
+	var subscriberDone <-chan struct{}
+	if d, ok := s.sub.Subscriber.(interface{ Done() <-chan struct{} }); ok {
+		subscriberDone = d.Done()
+	}
+	for {
+		select {
+		case env := <-s.mailbox.C():
+			b.deliverOne(s, env)
+		case <-until:
+			b.unsubscribe(s.id)
+			return
+		case <-subscriberDone:
+			b.unsubscribe(s.id)
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliverOne delivers a message to a subscriber, with the values of the
+// publisher's context, cancelled when the subscription ends.
+func (b *bus) deliverOne(s *subscription, env core.Envelope) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(env.Ctx))
+	defer cancel()
+	defer context.AfterFunc(s.ctx, cancel)()
+
+	if _, err := s.sub.Subscriber.Request(ctx, env.Msg); err != nil && s.ctx.Err() == nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+// matches determines whether the topic matches the subscription pattern.
+func matches(pattern, topic []string) bool {
+	for i, seg := range pattern {
+		if seg == "**" {
+			return true
+		}
+		if i >= len(topic) {
+			return false
+		}
+		if seg != "*" && seg != topic[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
@@ -0,0 +1,291 @@
+package bus
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder is a subscriber that sends each message it receives on a channel.
+type recorder struct {
+	core.BaseComponentReference
+	msgs chan core.Message
+}
+
+func newRecorder() *recorder {
+	return &recorder{msgs: make(chan core.Message, 10)}
+}
+
+func (r *recorder) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	r.msgs <- msg
+	return nil, nil
+}
+
+// expect fails the test unless the recorder receives exactly the given
+// messages, in order.
+func (r *recorder) expect(t *testing.T, msgs ...core.Message) {
+	t.Helper()
+
+	for _, want := range msgs {
+		select {
+		case got := <-r.msgs:
+			if got != want {
+				t.Fatalf("received %v, want %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("did not receive %v", want)
+		}
+	}
+	select {
+	case got := <-r.msgs:
+		t.Fatalf("unexpected message %v", got)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// doneReference is a subscriber with a Done channel, like a core.Component.
+type doneReference struct {
+	*comptest.FakeReference
+	done chan struct{}
+}
+
+func (d doneReference) Done() <-chan struct{} { return d.done }
+
+func subscribe(t *testing.T, h *comptest.Harness, sub Subscribe) {
+	t.Helper()
+
+	if _, err := h.Ref.Request(context.Background(), sub); err != nil {
+		t.Fatalf("Subscribe: %s", err)
+	}
+}
+
+func subscriptions(h *comptest.Harness) int {
+	return h.Component.(core.StatsComponent).Stats()["subscriptions"].(int)
+}
+
+// startBus starts the bus, stopping it when the test completes.
+func startBus(t *testing.T) core.ComponentReference {
+	return comptest.Start(t, Main, nil).Ref
+}
+
+func request(t *testing.T, ref core.ComponentReference, msg core.Message) core.Message {
+	t.Helper()
+
+	rsp, err := ref.Request(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("%T: %s", msg, err)
+	}
+	return rsp
+}
+
+func TestMatches(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"users.joined", "users.joined", true},
+		{"users.joined", "users.left", false},
+		{"users.*", "users.joined", true},
+		{"users.*", "users", false},
+		{"users.*", "users.joined.late", false},
+		{"users.**", "users", true},
+		{"users.**", "users.joined.late", true},
+		{"**", "anything.at.all", true},
+		{"*.joined", "rooms.joined", true},
+	} {
+		got := matches(strings.Split(tc.pattern, "."), strings.Split(tc.topic, "."))
+		if got != tc.want {
+			t.Errorf("matches(%q, %q) = %v, want %v", tc.pattern, tc.topic, got, tc.want)
+		}
+	}
+}
+
+func TestPublishDeliversToMatchingSubscribers(t *testing.T) {
+	ref := startBus(t)
+	users, all := newRecorder(), newRecorder()
+	request(t, ref, Subscribe{Topic: "users.*", Subscriber: users})
+	request(t, ref, Subscribe{Topic: "**", Subscriber: all})
+	request(t, ref, Subscribe{
+		Topic:      "**",
+		Subscriber: newRecorder(),
+		Filter:     func(topic string, msg core.Message) bool { return false },
+	})
+
+	for _, topic := range []string{"users.joined", "rooms.created", "users.left.early"} {
+		request(t, ref, Publish{Topic: topic, Message: topic})
+	}
+	users.expect(t, "users.joined")
+	all.expect(t, "users.joined", "rooms.created", "users.left.early")
+}
+
+func TestFilter(t *testing.T) {
+	ref := startBus(t)
+	even := newRecorder()
+	request(t, ref, Subscribe{
+		Topic:      "n",
+		Subscriber: even,
+		Filter:     func(topic string, msg core.Message) bool { return msg.(int)%2 == 0 },
+	})
+	for i := 1; i <= 4; i++ {
+		request(t, ref, Publish{Topic: "n", Message: i})
+	}
+	even.expect(t, 2, 4)
+}
+
+func TestUnsubscribeAndUntil(t *testing.T) {
+	ref := startBus(t)
+	unsubscribed, ended := newRecorder(), newRecorder()
+	rsp := request(t, ref, Subscribe{Topic: "t", Subscriber: unsubscribed})
+	until := make(chan struct{})
+	request(t, ref, Subscribe{Topic: "t", Subscriber: ended, Until: until})
+
+	request(t, ref, Publish{Topic: "t", Message: 1})
+	unsubscribed.expect(t, 1)
+	ended.expect(t, 1)
+
+	request(t, ref, Unsubscribe{ID: rsp.(Subscribed).ID})
+	close(until)
+	// the Until channel is handled asynchronously
+	time.Sleep(10 * time.Millisecond)
+	request(t, ref, Publish{Topic: "t", Message: 2})
+	unsubscribed.expect(t)
+	ended.expect(t)
+}
+
+func TestSubscribeRejectsInvalidSubscriptions(t *testing.T) {
+	ref := startBus(t)
+	for _, sub := range []Subscribe{
+		{Subscriber: newRecorder()},
+		{Topic: "t"},
+		{Topic: "users.**.joined", Subscriber: newRecorder()},
+	} {
+		if _, err := ref.Request(context.Background(), sub); err == nil {
+			t.Errorf("Subscribe %+v succeeded", sub)
+		}
+	}
+}
+
+func TestSubscriptionEndsWhenSubscriberIsDone(t *testing.T) {
+	h := comptest.Start(t, Main, nil)
+	sub := doneReference{comptest.NewFakeReference(), make(chan struct{})}
+	subscribe(t, h, Subscribe{Topic: "users.*", Subscriber: sub})
+	if n := subscriptions(h); n != 1 {
+		t.Fatalf("%d subscriptions after Subscribe, want 1", n)
+	}
+
+	close(sub.done)
+	deadline := time.Now().Add(comptest.DefaultTimeout)
+	for subscriptions(h) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not dropped after its subscriber was done")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStopReleasesBlockedPublishers(t *testing.T) {
+	h := comptest.Start(t, Main, nil)
+	stuck := comptest.NewFakeReference()
+	stuck.Handler = func(ctx context.Context, msg core.Message) (core.Message, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	subscribe(t, h, Subscribe{Topic: "t", Subscriber: stuck, Buffer: 1, Overflow: core.BlockOverflow})
+
+	// the first message is delivered, the second fills the mailbox, and the
+	// third blocks the publisher
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < 3; i++ {
+			h.Ref.Request(context.Background(), Publish{Topic: "t", Message: i})
+		}
+	}()
+	stuck.WaitForMessages(1, comptest.DefaultTimeout)
+
+	h.AssertStops(comptest.DefaultTimeout)
+	select {
+	case <-published:
+	case <-time.After(comptest.DefaultTimeout):
+		t.Fatal("publisher still blocked after the bus stopped")
+	}
+	if _, err := h.Ref.Request(context.Background(), Publish{Topic: "t", Message: 4}); err == nil {
+		t.Fatal("Publish succeeded after the bus stopped")
+	}
+}
+
+// fakeComponent is a component whose references are a FakeReference.
+type fakeComponent struct {
+	fake *comptest.FakeReference
+	done <-chan struct{}
+}
+
+func (c *fakeComponent) NewReference() core.ComponentReference { return c.fake }
+func (c *fakeComponent) Done() <-chan struct{}                 { return c.done }
+
+func TestSubscriptionEndsWhenReferencedComponentStops(t *testing.T) {
+	svc := comptest.NewFakeReference()
+	svc.Handler = func(ctx context.Context, msg core.Message) (core.Message, error) {
+		if _, ok := msg.(core.Reconfigure); ok {
+			return nil, fmt.Errorf("cannot reconfigure")
+		}
+		return nil, nil
+	}
+	var busRef, svcRef core.ComponentReference
+	orch := core.NewOrchestrator(
+		core.ComponentImpl{
+			Path:         "root",
+			Dependencies: []core.ComponentPath{componentPath, "svc"},
+			Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+				busRef, svcRef = deps[componentPath], deps["svc"]
+				return &fakeComponent{fake: comptest.NewFakeReference(), done: ctx.Done()}
+			},
+		},
+		Main,
+		core.ComponentImpl{
+			Path: "svc",
+			Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+				return &fakeComponent{fake: svc, done: ctx.Done()}
+			},
+		},
+	)
+	config := map[core.ComponentPath]core.Config{}
+	orch.SetConfigSource(func() (map[core.ComponentPath]core.Config, error) { return config, nil })
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer orch.Stop(context.Background())
+	busSubscriptions := func() int {
+		return orch.Status()[componentPath].Stats["subscriptions"].(int)
+	}
+
+	request(t, busRef, Subscribe{Topic: "t", Subscriber: svcRef})
+	request(t, busRef, Publish{Topic: "t", Message: "hello"})
+	svc.WaitForMessages(1, comptest.DefaultTimeout)
+
+	// restarting svc retires the reference, and so ends the subscription
+	config = map[core.ComponentPath]core.Config{"svc": core.Config(`{"changed": true}`)}
+	if _, err := orch.Reconfigure(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(comptest.DefaultTimeout)
+	for busSubscriptions() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not dropped after its subscriber stopped")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPublishRejectsWildcards(t *testing.T) {
+	ref := startBus(t)
+	for _, topic := range []string{"users.*", "**", "a.**"} {
+		if _, err := ref.Request(context.Background(), Publish{Topic: topic}); err == nil {
+			t.Errorf("Publish on %q succeeded", topic)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package bus
+
+import "comps/core"
+
+// Subscribe is a core.Message requesting that messages published on matching
+// topics be delivered to Subscriber.  The response is a Subscribed message.
+type Subscribe struct {
+	// Topic is the topic to subscribe to, possibly including wildcards.
+	Topic string
+
+	// Subscriber is the reference to which messages will be delivered.  If it
+	// has a `Done() <-chan struct{}` method, as a core.Component and the
+	// references the orchestrator hands out do, the subscription ends when
+	// that channel is closed.
+	Subscriber core.ComponentReference
+
+	// Filter, if not nil, is called for each matching message, and the
+	// message is only delivered if it returns true.  It is called from the
+	// publisher's goroutine, so it should be fast and must not block.
+	Filter func(topic string, msg core.Message) bool
+
+	// Buffer is the capacity of this subscriber's mailbox.  If zero, a
+	// default is used.
+	Buffer int
+
+	// Overflow is the policy applied when this subscriber's mailbox is full.
+	// The default is core.BlockOverflow, which blocks the publisher until
+	// there is room, the publisher's context is cancelled, or the
+	// subscription ends.
+	Overflow core.OverflowPolicy
+
+	// Until, if not nil, ends the subscription when it is closed.
+	// Subscribing components typically pass the Done channel of the context
+	// given to their Start function, so that the subscription ends when the
+	// component stops.
+	Until <-chan struct{}
+}
+
+// Subscribed is the response to a Subscribe message.
+type Subscribed struct {
+	// ID identifies the subscription, for use with Unsubscribe.
+	ID int
+}
+
+// Unsubscribe is a core.Message ending a subscription.
+type Unsubscribe struct {
+	// ID is the subscription ID from the Subscribed message.
+	ID int
+}
+
+// Publish is a core.Message publishing a message on a topic.  The message is
+// delivered, as-is, to every matching subscriber.
+type Publish struct {
+	// Topic is the topic on which to publish.  It must not contain wildcards;
+	// publishing on such a topic fails.
+	Topic string
+
+	// Message is the message to deliver to subscribers.
+	Message core.Message
+}
@@ -0,0 +1,125 @@
+package deadletter
+
+import (
+	"comps/core"
+	"comps/core/comp/debug"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+var componentPath core.ComponentPath = "core/comp/deadletter.Main"
+
+// historySize is the number of dead letters retained
+const historySize = 100
+
+// Main is the component implementation for this package (`core/comp/deadletter.Main`).
+//
+// This component registers itself with the orchestrator to receive every
+// failed asynchronous request as a `core.DeadLetter` message.  It keeps the
+// most recent of these, which are available with the `HistoryRequest`
+// message and on the debug server at `/deadletters`.
+var Main = core.ComponentImpl{
+	Path:         componentPath,
+	Dependencies: []core.ComponentPath{"core/comp/debug.Main"},
+	Provides:     []core.Service{debug.PageService},
+	Capabilities: []core.Capability{core.SinkCapability},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		dl := &deadLetters{}
+		deps["core/comp/debug.Main"].RequestAsync(
+			ctx,
+			debug.RegisterHandler{
+				Name:    "Dead Letters",
+				Pattern: "/deadletters",
+				Handler: http.HandlerFunc(dl.handler),
+			})
+		host.Sinks().HandleDeadLetters(dl)
+		return dl
+	},
+}
+
+// HistoryRequest requests the retained dead letters, oldest first.
+type HistoryRequest struct{}
+
+// Idempotent implements core.Idempotent.
+func (HistoryRequest) Idempotent() {}
+
+// HistoryResponse contains the retained dead letters, oldest first.
+type HistoryResponse struct {
+	DeadLetters []core.DeadLetter
+}
+
+type deadLetters struct {
+	core.BaseComponent
+	mu sync.Mutex
+
+	// history is a ring buffer of dead letters; next is the index at which
+	// the next dead letter will be written
+	history []core.DeadLetter
+	next    int
+}
+
+var _ core.Component = &deadLetters{}
+var _ core.ComponentReference = &deadLetters{}
+
+// NewReference implements core.Component#NewReference.
+func (dl *deadLetters) NewReference() core.ComponentReference {
+	return dl
+}
+
+// Request implements core.ComponentReference#Request.
+func (dl *deadLetters) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	switch v := msg.(type) {
+	case core.DeadLetter:
+		dl.add(v)
+		return nil, nil
+	case HistoryRequest:
+		return HistoryResponse{DeadLetters: dl.snapshot()}, nil
+	default:
+		return nil, fmt.Errorf("Unrecognized message type %T", msg)
+	}
+}
+
+// RequestAsync implements core.ComponentReference#RequestAsync.
+func (dl *deadLetters) RequestAsync(ctx context.Context, msg core.Message) {
+	if _, err := dl.Request(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+func (dl *deadLetters) add(letter core.DeadLetter) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	if len(dl.history) < historySize {
+		dl.history = append(dl.history, letter)
+	} else {
+		dl.history[dl.next] = letter
+	}
+	dl.next = (dl.next + 1) % historySize
+}
+
+func (dl *deadLetters) snapshot() []core.DeadLetter {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	rv := make([]core.DeadLetter, 0, len(dl.history))
+	if len(dl.history) == historySize {
+		rv = append(rv, dl.history[dl.next:]...)
+		rv = append(rv, dl.history[:dl.next]...)
+	} else {
+		rv = append(rv, dl.history...)
+	}
+	return rv
+}
+
+func (dl *deadLetters) handler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8") // normal header
+	w.WriteHeader(http.StatusOK)
+	for _, letter := range dl.snapshot() {
+		fmt.Fprintf(w, "%s: %s -> %s\n", letter.Time.Format("15:04:05.000"), letter.Caller, letter.Target)
+		fmt.Fprintf(w, "  Message: %#v\n", letter.Message)
+		fmt.Fprintf(w, "  Error: %s\n", letter.Err)
+	}
+}
@@ -0,0 +1,28 @@
+package deadletter
+
+import (
+	"comps/core"
+	"context"
+	"testing"
+)
+
+func TestHistoryKeepsMostRecent(t *testing.T) {
+	dl := &deadLetters{}
+	for i := 0; i < historySize+5; i++ {
+		dl.Request(context.Background(), core.DeadLetter{Message: i})
+	}
+
+	rsp, err := dl.Request(context.Background(), HistoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	letters := rsp.(HistoryResponse).DeadLetters
+	if len(letters) != historySize {
+		t.Fatalf("%d dead letters retained, want %d", len(letters), historySize)
+	}
+	for i, letter := range letters {
+		if letter.Message != i+5 {
+			t.Fatalf("dead letter %d is %v, want %d", i, letter.Message, i+5)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Code generated by compgen; DO NOT EDIT.
+
+package debug
+
+import (
+	"comps/core"
+	"context"
+	"fmt"
+)
+
+var _ core.Component = &main{}
+var _ core.ComponentReference = &main{}
+
+// NewReference implements core.Component#NewReference.
+func (m *main) NewReference() core.ComponentReference {
+	return m
+}
+
+// Request implements core.ComponentReference#Request.
+func (m *main) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	switch v := msg.(type) {
+	case RegisterHandler:
+		return nil, m.handleRegisterHandler(ctx, v)
+	case Serve:
+		return nil, m.handleServe(ctx, v)
+	case HandlerRequest:
+		return m.handleHandlerRequest(ctx, v)
+	default:
+		return nil, fmt.Errorf("Unrecognized message type %T", v)
+	}
+}
+
+// RequestAsync implements core.ComponentReference#RequestAsync.
+func (m *main) RequestAsync(ctx context.Context, msg core.Message) {
+	if _, err := m.Request(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+// MainClient is a typed client for the Main component.
+type MainClient struct {
+	ref core.ComponentReference
+}
+
+// WrapMain returns a MainClient for the Main component, which must
+// be among the given dependencies.
+func WrapMain(deps map[core.ComponentPath]core.ComponentReference) MainClient {
+	return MainClient{ref: deps[componentPath("Main")]}
+}
+
+// NewMainClient returns a MainClient wrapping the given reference.
+func NewMainClient(ref core.ComponentReference) MainClient {
+	return MainClient{ref: ref}
+}
+
+// Reference returns the wrapped reference.
+func (c MainClient) Reference() core.ComponentReference {
+	return c.ref
+}
+
+// RegisterHandler sends msg and waits for it to be handled.
+func (c MainClient) RegisterHandler(ctx context.Context, msg RegisterHandler) error {
+	_, err := c.ref.Request(ctx, msg)
+	return err
+}
+
+// RegisterHandlerAsync sends msg without waiting.
+func (c MainClient) RegisterHandlerAsync(ctx context.Context, msg RegisterHandler) {
+	c.ref.RequestAsync(ctx, msg)
+}
+
+// Serve sends msg and waits for it to be handled.
+func (c MainClient) Serve(ctx context.Context, msg Serve) error {
+	_, err := c.ref.Request(ctx, msg)
+	return err
+}
+
+// ServeAsync sends msg without waiting.
+func (c MainClient) ServeAsync(ctx context.Context, msg Serve) {
+	c.ref.RequestAsync(ctx, msg)
+}
+
+// HandlerRequest sends msg and waits for the response.
+func (c MainClient) HandlerRequest(ctx context.Context, msg HandlerRequest) (HandlerResponse, error) {
+	var zero HandlerResponse
+	rsp, err := c.ref.Request(ctx, msg)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := rsp.(HandlerResponse)
+	if !ok {
+		return zero, fmt.Errorf("Unexpected response type %T", rsp)
+	}
+	return typed, nil
+}
+
+// HandlerRequestAsync sends msg without waiting.
+func (c MainClient) HandlerRequestAsync(ctx context.Context, msg HandlerRequest) {
+	c.ref.RequestAsync(ctx, msg)
+}
@@ -11,8 +11,9 @@ import (
 var Expvar = core.ComponentImpl{
 	Path:         componentPath("Expvar"),
 	Dependencies: []core.ComponentPath{"core/comp/debug.Main"},
-	Start: func(orch *core.Orchestrator, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
-		deps["core/comp/debug.Main"].RequestAsync(
+	Provides:     []core.Service{PageService},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		WrapMain(deps).RegisterHandlerAsync(
 			ctx,
 			RegisterHandler{
 				Name:    "expvar",
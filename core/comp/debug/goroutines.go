@@ -0,0 +1,58 @@
+package debug
+
+import (
+	"comps/core"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Goroutines will include a goroutine dump, grouped by the component owning
+// each goroutine, at /goroutines in the `core/comp/debug.Main` component's
+// http handler.
+var Goroutines = core.ComponentImpl{
+	Path:         componentPath("Goroutines"),
+	Dependencies: []core.ComponentPath{"core/comp/debug.Main"},
+	Provides:     []core.Service{PageService},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		WrapMain(deps).RegisterHandlerAsync(
+			ctx,
+			RegisterHandler{
+				Name:    "Goroutines",
+				Pattern: "/goroutines",
+				Handler: http.HandlerFunc(goroutinesHandler),
+			})
+		return &goroutines{}
+	},
+}
+
+type goroutines struct{ core.BaseComponent }
+
+func goroutinesHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	groups := core.Goroutines()
+	totals := map[core.ComponentPath]int{}
+	for _, g := range groups {
+		totals[g.Component] += g.Count
+	}
+
+	var current *core.ComponentPath
+	for _, g := range groups {
+		if current == nil || *current != g.Component {
+			path := g.Component
+			current = &path
+			name := string(path)
+			if name == "" {
+				name = "(no component)"
+			}
+			fmt.Fprintf(w, "%s: %d goroutines\n", name, totals[path])
+		}
+		fmt.Fprintf(w, "  %d with stack:\n", g.Count)
+		for _, frame := range strings.Split(g.Stack, "\n") {
+			fmt.Fprintf(w, "    %s\n", frame)
+		}
+	}
+}
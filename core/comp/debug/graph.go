@@ -0,0 +1,127 @@
+package debug
+
+import (
+	"comps/core"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+)
+
+// layout constants for renderSVG, in pixels
+const (
+	svgCharWidth   = 7
+	svgNodeHeight  = 30
+	svgNodePadding = 16
+	svgColumnGap   = 24
+	svgRowGap      = 60
+	svgMargin      = 20
+)
+
+type svgNode struct {
+	node  core.GraphNode
+	layer int
+	x, y  int
+	width int
+}
+
+// renderSVG renders the graph as an SVG image, with the root at the top and
+// each component placed below everything that depends on it.  This is a
+// simple layered layout, so that the debug server need not rely on external
+// tools such as Graphviz.
+func renderSVG(w io.Writer, g core.Graph) {
+	nodes := map[core.ComponentPath]*svgNode{}
+	for _, n := range g.Nodes {
+		nodes[n.Path] = &svgNode{
+			node:  n,
+			width: len(n.Path)*svgCharWidth + 2*svgNodePadding,
+		}
+	}
+	deps := map[core.ComponentPath][]core.ComponentPath{}
+	for _, e := range g.Edges {
+		deps[e.From] = append(deps[e.From], e.To)
+	}
+
+	// assign each node a layer one below the deepest of its dependents,
+	// ignoring any edges that would form a cycle
+	onStack := map[core.ComponentPath]bool{}
+	var place func(path core.ComponentPath, layer int)
+	place = func(path core.ComponentPath, layer int) {
+		n, found := nodes[path]
+		if !found || onStack[path] || (n.layer >= layer && layer != 0) {
+			return
+		}
+		n.layer = layer
+		onStack[path] = true
+		for _, dep := range deps[path] {
+			place(dep, layer+1)
+		}
+		onStack[path] = false
+	}
+	place(g.Root, 0)
+
+	// arrange the layers into rows
+	rows := [][]*svgNode{}
+	for _, n := range g.Nodes {
+		sn := nodes[n.Path]
+		for len(rows) <= sn.layer {
+			rows = append(rows, nil)
+		}
+		rows[sn.layer] = append(rows[sn.layer], sn)
+	}
+	width := 0
+	for _, row := range rows {
+		rowWidth := -svgColumnGap
+		for _, sn := range row {
+			rowWidth += sn.width + svgColumnGap
+		}
+		if rowWidth > width {
+			width = rowWidth
+		}
+	}
+	for i, row := range rows {
+		sort.Slice(row, func(a, b int) bool { return row[a].node.Path < row[b].node.Path })
+		rowWidth := -svgColumnGap
+		for _, sn := range row {
+			rowWidth += sn.width + svgColumnGap
+		}
+		x := svgMargin + (width-rowWidth)/2
+		for _, sn := range row {
+			sn.x = x
+			sn.y = svgMargin + i*(svgNodeHeight+svgRowGap)
+			x += sn.width + svgColumnGap
+		}
+	}
+	height := 2*svgMargin + len(rows)*(svgNodeHeight+svgRowGap) - svgRowGap
+
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"12\">\n",
+		width+2*svgMargin, height)
+	fmt.Fprintf(w, "  <defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"8\" markerHeight=\"8\" orient=\"auto\">")
+	fmt.Fprintf(w, "<path d=\"M 0 0 L 10 5 L 0 10 z\"/></marker></defs>\n")
+	for _, e := range g.Edges {
+		from, to := nodes[e.From], nodes[e.To]
+		if from == nil || to == nil {
+			continue
+		}
+		dash := ""
+		if e.Dynamic {
+			dash = " stroke-dasharray=\"4 3\""
+		}
+		fmt.Fprintf(w, "  <line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#555\"%s marker-end=\"url(#arrow)\"/>\n",
+			from.x+from.width/2, from.y+svgNodeHeight, to.x+to.width/2, to.y, dash)
+	}
+	for _, n := range g.Nodes {
+		sn := nodes[n.Path]
+		strokeWidth := 1
+		if n.Path == g.Root {
+			strokeWidth = 2
+		}
+		fmt.Fprintf(w, "  <g><title>%s: %s</title>\n", html.EscapeString(string(n.Path)), html.EscapeString(string(n.State)))
+		fmt.Fprintf(w, "    <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"4\" fill=\"%s\" stroke=\"#333\" stroke-width=\"%d\"/>\n",
+			sn.x, sn.y, sn.width, svgNodeHeight, core.StateColor(n.State), strokeWidth)
+		fmt.Fprintf(w, "    <text x=\"%d\" y=\"%d\" text-anchor=\"middle\" dominant-baseline=\"middle\">%s</text>\n",
+			sn.x+sn.width/2, sn.y+svgNodeHeight/2, html.EscapeString(string(n.Path)))
+		fmt.Fprintf(w, "  </g>\n")
+	}
+	fmt.Fprintf(w, "</svg>\n")
+}
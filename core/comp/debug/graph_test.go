@@ -0,0 +1,29 @@
+package debug
+
+import (
+	"comps/core"
+	"strings"
+	"testing"
+)
+
+func TestRenderSVG(t *testing.T) {
+	var b strings.Builder
+	renderSVG(&b, core.Graph{
+		Root: "root",
+		Nodes: []core.GraphNode{
+			{Path: "dep", State: core.StoppedState},
+			{Path: "root", State: core.RunningState},
+		},
+		Edges: []core.GraphEdge{{From: "root", To: "dep"}},
+	})
+	svg := b.String()
+
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(strings.TrimSpace(svg), "</svg>") {
+		t.Fatalf("not an SVG document:\n%s", svg)
+	}
+	for _, want := range []string{">root<", ">dep<", core.StateColor(core.StoppedState), "<line"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG does not contain %q:\n%s", want, svg)
+		}
+	}
+}
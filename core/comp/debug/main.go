@@ -6,12 +6,20 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 )
 
+//go:generate go run comps/cmd/compgen
+
 func componentPath(suffix string) core.ComponentPath {
 	return core.ComponentPath("core/comp/debug." + suffix)
 }
 
+// PageService is provided by components that register a page with Main.  A
+// root component can require all of its providers to include every available
+// page, without listing them.
+const PageService core.Service = "debug-page"
+
 // Main is the component implementation for this package (`comp/debug.Main`).
 //
 // This component manages an `http.Handler` containing component debug
@@ -21,38 +29,47 @@ func componentPath(suffix string) core.ComponentPath {
 //
 // Other components can register handlers on this component with the
 // `RegisterHandler` message.  Other components in this package do exactly
-// that.
+// that.  Registering a pattern again replaces its handler, so a component
+// that restarts can register its pages again.
+//
+// Sending `Serve` again with a different port moves the server to that port.
 var Main = core.ComponentImpl{
 	Path:         componentPath("Main"),
 	Dependencies: []core.ComponentPath{},
-	Start: func(orch *core.Orchestrator, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
-		done := make(chan struct{})
-		close(done)
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
 		m := &main{
-			handler:    http.NewServeMux(),
+			handlers:   make(map[string]http.Handler),
 			registered: make(map[string]string),
 			ctx:        ctx,
-			done:       done,
+			done:       make(chan struct{}),
 		}
 		m.register("", "/", http.HandlerFunc(m.root))
+		go m.run()
 		return m
 	},
 }
 
+//comp:component Main
 type main struct {
 	core.BaseComponent
-	handler    *http.ServeMux
-	registered map[string]string
-	ctx        context.Context
-	done       chan struct{}
-}
+	ctx  context.Context
+	done chan struct{}
+
+	// mu protects handlers, mux, registered, server, and port
+	mu sync.Mutex
 
-var _ core.Component = &main{}
-var _ core.ComponentReference = &main{}
+	// handlers maps each registered pattern to its handler, and mux serves
+	// them; it is rebuilt whenever a handler is registered
+	handlers map[string]http.Handler
+	mux      *http.ServeMux
 
-// NewReference implements core.Component#NewReference.
-func (m *main) NewReference() core.ComponentReference {
-	return m
+	// registered maps names in the table of contents to patterns
+	registered map[string]string
+	server     *http.Server
+	port       int
+
+	// serving tracks running servers
+	serving sync.WaitGroup
 }
 
 // Done implements core.Component#Done.
@@ -60,50 +77,102 @@ func (m *main) Done() <-chan struct{} {
 	return m.done
 }
 
-// Request implements core.ComponentReference#Request.
-func (m *main) Request(ctx context.Context, msg core.Message) (core.Message, error) {
-	switch v := msg.(type) {
-	case HandlerRequest:
-		return HandlerResponse{m.handler}, nil
-	case Serve:
-		m.serve(v.Port)
-		return nil, nil
-	case RegisterHandler:
-		m.register(v.Name, v.Pattern, v.Handler)
-		return nil, nil
-	default:
-		return nil, fmt.Errorf("Unrecognized message type %T", msg)
-	}
+func (m *main) handleHandlerRequest(ctx context.Context, msg HandlerRequest) (HandlerResponse, error) {
+	return HandlerResponse{http.HandlerFunc(m.serveHTTP)}, nil
+}
+
+func (m *main) handleServe(ctx context.Context, msg Serve) error {
+	m.serve(msg.Port)
+	return nil
 }
 
-// RequestAsync implements core.ComponentReference#RequestAsync.
-func (m *main) RequestAsync(ctx context.Context, msg core.Message) {
-	m.Request(ctx, msg)
+func (m *main) handleRegisterHandler(ctx context.Context, msg RegisterHandler) error {
+	return m.register(msg.Name, msg.Pattern, msg.Handler)
+}
+
+func (m *main) run() {
+	<-m.ctx.Done()
+	m.shutdown()
+	m.serving.Wait()
+	close(m.done)
 }
 
 func (m *main) serve(port int) {
-	m.done = make(chan struct{}) // an un-closed channel
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ctx.Err() != nil || (m.server != nil && m.port == port) {
+		return
+	}
+	if m.server != nil {
+		// context passed to Shutdown is the timeline for the shutdown to complete; m.ctx
+		// may already be done, so would not be a good choice here.
+		m.server.Shutdown(context.Background())
+	}
+
 	s := &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
-		Handler: m.handler,
+		Handler: http.HandlerFunc(m.serveHTTP),
 	}
+	m.server = s
+	m.port = port
+	m.serving.Add(1)
 	go func() {
-		<-m.ctx.Done()
-		// context passed to Shutdown is the timeline for the shutdown to complete; m.ctx
-		// is already done, so would not be a good choice here.
-		s.Shutdown(context.Background())
-	}()
-	go func() {
-		defer close(m.done)
+		defer m.serving.Done()
 		s.ListenAndServe()
 	}()
 }
 
-func (m *main) register(name, pattern string, handler http.Handler) {
-	m.handler.Handle(pattern, handler)
+func (m *main) shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.server != nil {
+		m.server.Shutdown(context.Background())
+		m.server = nil
+	}
+}
+
+// register adds a handler for the given pattern, replacing any handler
+// already registered for it.  An invalid pattern is an error.
+func (m *main) register(name, pattern string, handler http.Handler) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// http.ServeMux#Handle panics on an invalid pattern
+	defer func() {
+		if value := recover(); value != nil {
+			err = fmt.Errorf("Cannot register %q: %v", pattern, value)
+		}
+	}()
+	mux := http.NewServeMux()
+	mux.Handle(pattern, handler)
+	for p, h := range m.handlers {
+		if p != pattern {
+			mux.Handle(p, h)
+		}
+	}
+	m.handlers[pattern] = handler
+	m.mux = mux
+
+	for n, p := range m.registered {
+		if p == pattern {
+			delete(m.registered, n)
+		}
+	}
 	if name != "" {
 		m.registered[name] = pattern
 	}
+	return nil
+}
+
+// serveHTTP serves a request with the handlers registered so far.
+func (m *main) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	m.mu.Lock()
+	mux := m.mux
+	m.mu.Unlock()
+
+	mux.ServeHTTP(w, req)
 }
 
 func (m *main) root(w http.ResponseWriter, req *http.Request) {
@@ -118,9 +187,11 @@ func (m *main) root(w http.ResponseWriter, req *http.Request) {
 		"<h1>Comps Debug</h1>",
 		"<ul>",
 	}
+	m.mu.Lock()
 	for name, path := range m.registered {
 		toc = append(toc, fmt.Sprintf("  <li><a href=\"%s\">%s</a></li>", path[1:], name))
 	}
+	m.mu.Unlock()
 	toc = append(toc,
 		"</ul>",
 		"</body>",
@@ -129,7 +200,9 @@ func (m *main) root(w http.ResponseWriter, req *http.Request) {
 	fmt.Fprintf(w, strings.Join(toc, "\n"))
 }
 
-// RegisterHandler requests the singleton http.Handler from this component
+// RegisterHandler registers an http.Handler with this component
+//
+//comp:message Main
 type RegisterHandler struct {
 	// Name is the human-readable name for this handler.  It will appear in the
 	// table of contents.  If this is empty, the handler will not be included
@@ -144,15 +217,22 @@ type RegisterHandler struct {
 	Handler http.Handler
 }
 
-// Serve requests the singleton http.Handler from this component
+// Serve requests that this component run an HTTP server for its handler
+//
+//comp:message Main
 type Serve struct {
 	// Port is the port on which to run the server
 	Port int
 }
 
 // HandlerRequest requests the singleton http.Handler from this component
+//
+//comp:message Main response=HandlerResponse
 type HandlerRequest struct{}
 
+// Idempotent implements core.Idempotent.
+func (HandlerRequest) Idempotent() {}
+
 // HandlerResponse returns the singleton http.Handler from this component
 type HandlerResponse struct {
 	Handler http.Handler
@@ -0,0 +1,71 @@
+package debug_test
+
+import (
+	"comps/core/comp/debug"
+	"comps/core/comptest"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// get serves a GET request for path with the component's handler, and returns
+// the response body.
+func get(t *testing.T, client debug.MainClient, path string) string {
+	t.Helper()
+
+	rsp, err := client.HandlerRequest(context.Background(), debug.HandlerRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	rsp.Handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	body, _ := io.ReadAll(rec.Result().Body)
+	return string(body)
+}
+
+func page(text string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, text)
+	})
+}
+
+func TestRegisterHandlerReplaces(t *testing.T) {
+	h := comptest.Start(t, debug.Main, nil)
+	client := debug.NewMainClient(h.Ref)
+	ctx := context.Background()
+
+	if err := client.RegisterHandler(ctx, debug.RegisterHandler{Name: "Old", Pattern: "/page", Handler: page("old")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RegisterHandler(ctx, debug.RegisterHandler{Name: "New", Pattern: "/page", Handler: page("new")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := get(t, client, "/page"); got != "new" {
+		t.Errorf("/page served %q, want %q", got, "new")
+	}
+	toc := get(t, client, "/")
+	if !strings.Contains(toc, "New") || strings.Contains(toc, "Old") {
+		t.Errorf("table of contents does not list only the new page:\n%s", toc)
+	}
+}
+
+func TestRegisterHandlerInvalidPattern(t *testing.T) {
+	h := comptest.Start(t, debug.Main, nil)
+	client := debug.NewMainClient(h.Ref)
+	ctx := context.Background()
+
+	if err := client.RegisterHandler(ctx, debug.RegisterHandler{Pattern: "", Handler: page("bad")}); err == nil {
+		t.Error("registering an empty pattern succeeded")
+	}
+	if err := client.RegisterHandler(ctx, debug.RegisterHandler{Pattern: "/page", Handler: page("good")}); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(t, client, "/page"); got != "good" {
+		t.Errorf("/page served %q, want %q", got, "good")
+	}
+}
@@ -3,39 +3,157 @@ package debug
 import (
 	"comps/core"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 )
 
+// Orchestrator will include information about the orchestrator in the
+// `core/comp/debug.Main` component's http handler: a text summary at
+// /orchestrator, the dependency graph as JSON at /orchestrator.json and as
+// Graphviz DOT at /orchestrator/graph.dot, and a rendering of the graph at
+// /orchestrator/graph.
 var Orchestrator = core.ComponentImpl{
 	Path:         componentPath("Orchestrator"),
 	Dependencies: []core.ComponentPath{"core/comp/debug.Main"},
-	Start: func(orch *core.Orchestrator, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
-		o := &orchestrator{orch: orch}
-		deps["core/comp/debug.Main"].RequestAsync(
+	Provides:     []core.Service{PageService},
+	Capabilities: []core.Capability{core.StatusCapability},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		o := &orchestrator{orch: host.Status()}
+		debugMain := WrapMain(deps)
+		debugMain.RegisterHandlerAsync(
 			ctx,
 			RegisterHandler{
 				Name:    "Orchestrator",
 				Pattern: "/orchestrator",
 				Handler: http.HandlerFunc(o.handler),
 			})
+		debugMain.RegisterHandlerAsync(
+			ctx,
+			RegisterHandler{
+				Name:    "Orchestrator Graph",
+				Pattern: "/orchestrator/graph",
+				Handler: http.HandlerFunc(o.graphHandler),
+			})
+		debugMain.RegisterHandlerAsync(
+			ctx,
+			RegisterHandler{
+				Pattern: "/orchestrator/graph.dot",
+				Handler: http.HandlerFunc(o.dotHandler),
+			})
+		debugMain.RegisterHandlerAsync(
+			ctx,
+			RegisterHandler{
+				Pattern: "/orchestrator.json",
+				Handler: http.HandlerFunc(o.jsonHandler),
+			})
 		return o
 	},
 }
 
 type orchestrator struct {
 	core.BaseComponent
-	orch *core.Orchestrator
+	orch core.StatusReader
 }
 
 func (o *orchestrator) handler(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8") // normal header
 	w.WriteHeader(http.StatusOK)
-	for comp, status := range o.orch.Status() {
+	statuses := o.orch.Status()
+	paths := make([]core.ComponentPath, 0, len(statuses))
+	for path := range statuses {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	for _, comp := range paths {
+		status := statuses[comp]
 		fmt.Fprintf(w, "%s: %s\n", string(comp), status.State)
+		if status.Overridden {
+			by := string(status.OverriddenBy)
+			if by == "" {
+				by = "(unnamed implementation)"
+			}
+			fmt.Fprintf(w, "  Overridden by: %s\n", by)
+		}
+		fmt.Fprintf(w, "  Started: %s (up %s, Start took %s)\n",
+			status.StartTime.Format(time.RFC3339), status.Uptime.Round(time.Second), status.StartDuration)
+		fmt.Fprintf(w, "  Restarts: %d, failures: %d, timeouts: %d, denied: %d\n", status.Restarts, status.Failures, status.Timeouts, status.Denied)
+		if status.LastError != nil {
+			fmt.Fprintf(w, "  Last error: %s (at %s)\n", status.LastError, status.LastErrorTime.Format(time.RFC3339))
+			var panicErr *core.PanicError
+			if errors.As(status.LastError, &panicErr) {
+				for _, line := range strings.Split(strings.TrimSpace(panicErr.Stack), "\n") {
+					fmt.Fprintf(w, "    %s\n", line)
+				}
+			}
+		}
 		fmt.Fprintf(w, "  Depends on:\n")
 		for _, d := range status.Dependencies {
 			fmt.Fprintf(w, "    %s\n", string(d))
 		}
+		fmt.Fprintf(w, "  Depended on by:\n")
+		for _, d := range status.Dependents {
+			fmt.Fprintf(w, "    %s\n", string(d))
+		}
+		if len(status.Breakers) > 0 {
+			fmt.Fprintf(w, "  Circuit breakers:\n")
+			deps := make([]core.ComponentPath, 0, len(status.Breakers))
+			for dep := range status.Breakers {
+				deps = append(deps, dep)
+			}
+			sort.Slice(deps, func(i, j int) bool { return deps[i] < deps[j] })
+			for _, dep := range deps {
+				fmt.Fprintf(w, "    %s: %s\n", dep, status.Breakers[dep])
+			}
+		}
+		if mb := status.Mailbox; mb != nil {
+			fmt.Fprintf(w, "  Mailbox: %d/%d queued (%s), %d dropped, %d rejected\n",
+				mb.Depth, mb.Capacity, mb.Overflow, mb.Dropped, mb.Rejected)
+		}
+		if len(status.Stats) > 0 {
+			fmt.Fprintf(w, "  Stats:\n")
+			names := make([]string, 0, len(status.Stats))
+			for name := range status.Stats {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(w, "    %s: %v\n", name, status.Stats[name])
+			}
+		}
+	}
+}
+
+func (o *orchestrator) dotHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, o.orch.GraphDOT())
+}
+
+func (o *orchestrator) jsonHandler(w http.ResponseWriter, req *http.Request) {
+	body, err := o.orch.GraphJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func (o *orchestrator) graphHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "<html>\n<head><title>Orchestrator Graph</title></head>\n<body>\n")
+	fmt.Fprintf(w, "<h1>Orchestrator Graph</h1>\n")
+	fmt.Fprintf(w, "<p>")
+	for _, state := range []core.ComponentState{core.RunningState, core.StoppingState, core.StoppedState, core.FailedState} {
+		fmt.Fprintf(w, "<span style=\"background: %s; padding: 2px 6px; margin-right: 4px\">%s</span>", core.StateColor(state), state)
 	}
+	fmt.Fprintf(w, " (<a href=\"graph.dot\">DOT</a>, <a href=\"../orchestrator.json\">JSON</a>)</p>\n")
+	renderSVG(w, o.orch.Graph())
+	fmt.Fprintf(w, "</body>\n</html>\n")
 }
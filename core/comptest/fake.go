@@ -0,0 +1,143 @@
+package comptest
+
+import (
+	"comps/core"
+	"context"
+	"sync"
+	"time"
+)
+
+// FakeReference is a core.ComponentReference that records the messages sent
+// to it and returns scripted responses.  It is safe for concurrent use.
+type FakeReference struct {
+	mu        sync.Mutex
+	received  []Received
+	responses []response
+	changed   chan struct{}
+
+	// Handler, if not nil, is called for each request that does not have a
+	// scripted response.  If nil, such requests return (nil, nil).
+	Handler func(context.Context, core.Message) (core.Message, error)
+}
+
+// Received is a message received by a FakeReference.
+type Received struct {
+	// Ctx is the context with which the message was sent.
+	Ctx context.Context
+
+	// Msg is the message.
+	Msg core.Message
+
+	// Async is true if the message was sent with RequestAsync.
+	Async bool
+}
+
+type response struct {
+	msg core.Message
+	err error
+}
+
+var _ core.ComponentReference = &FakeReference{}
+
+// NewFakeReference creates a new FakeReference with no scripted responses.
+func NewFakeReference() *FakeReference {
+	return &FakeReference{changed: make(chan struct{})}
+}
+
+// Respond adds a scripted response.  Scripted responses are returned, in
+// order, from subsequent requests; once they are exhausted, Handler is used.
+// It returns the FakeReference, so calls can be chained.
+func (f *FakeReference) Respond(msg core.Message, err error) *FakeReference {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.responses = append(f.responses, response{msg, err})
+	return f
+}
+
+// Request implements core.ComponentReference#Request.
+func (f *FakeReference) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	handler, resp, scripted := f.record(ctx, msg, false)
+	if scripted {
+		return resp.msg, resp.err
+	}
+	if handler != nil {
+		return handler(ctx, msg)
+	}
+	return nil, nil
+}
+
+// RequestAsync implements core.ComponentReference#RequestAsync.  Scripted
+// responses are consumed as for Request; if the response (or Handler) returns
+// an error, it is reported with core.ReportAsyncFailure.
+func (f *FakeReference) RequestAsync(ctx context.Context, msg core.Message) {
+	handler, resp, scripted := f.record(ctx, msg, true)
+	err := resp.err
+	if !scripted && handler != nil {
+		_, err = handler(ctx, msg)
+	}
+	if err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+func (f *FakeReference) record(ctx context.Context, msg core.Message, async bool) (func(context.Context, core.Message) (core.Message, error), response, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.received = append(f.received, Received{Ctx: ctx, Msg: msg, Async: async})
+	close(f.changed)
+	f.changed = make(chan struct{})
+
+	if len(f.responses) > 0 {
+		resp := f.responses[0]
+		f.responses = f.responses[1:]
+		return f.Handler, resp, true
+	}
+	return f.Handler, response{}, false
+}
+
+// Received returns all messages received so far, in order.
+func (f *FakeReference) Received() []Received {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Received{}, f.received...)
+}
+
+// Messages returns the messages received so far, in order.
+func (f *FakeReference) Messages() []core.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rv := make([]core.Message, len(f.received))
+	for i, r := range f.received {
+		rv[i] = r.Msg
+	}
+	return rv
+}
+
+// WaitForMessages waits until at least n messages have been received, or the
+// timeout expires, and returns the messages received so far.  This is useful
+// for components that send messages from their own goroutines.
+func (f *FakeReference) WaitForMessages(n int, timeout time.Duration) []core.Message {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		f.mu.Lock()
+		count := len(f.received)
+		changed := f.changed
+		f.mu.Unlock()
+
+		if count >= n {
+			return f.Messages()
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return f.Messages()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package comptest_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFakeReferenceScriptedResponses(t *testing.T) {
+	errScripted := errors.New("scripted")
+	fake := comptest.NewFakeReference().Respond("first", nil).Respond(nil, errScripted)
+	fake.Handler = func(ctx context.Context, msg core.Message) (core.Message, error) {
+		return "handled " + msg.(string), nil
+	}
+
+	for _, want := range []struct {
+		rsp core.Message
+		err error
+	}{{"first", nil}, {nil, errScripted}, {"handled c", nil}} {
+		rsp, err := fake.Request(context.Background(), "c")
+		if rsp != want.rsp || err != want.err {
+			t.Fatalf("got %v, %v; want %v, %v", rsp, err, want.rsp, want.err)
+		}
+	}
+}
+
+func TestFakeReferenceRecordsMessages(t *testing.T) {
+	fake := comptest.NewFakeReference()
+	fake.Request(context.Background(), "sync")
+	go fake.RequestAsync(context.Background(), "async")
+
+	got := fake.WaitForMessages(2, comptest.DefaultTimeout)
+	if !reflect.DeepEqual(got, []core.Message{"sync", "async"}) {
+		t.Fatalf("messages %v, want [sync async]", got)
+	}
+	received := fake.Received()
+	if received[0].Async || !received[1].Async {
+		t.Fatalf("received %+v, want only the second to be async", received)
+	}
+}
+
+func TestWaitForMessagesTimesOut(t *testing.T) {
+	fake := comptest.NewFakeReference()
+	start := time.Now()
+	if got := fake.WaitForMessages(1, 10*time.Millisecond); len(got) != 0 {
+		t.Fatalf("messages %v, want none", got)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Fatal("WaitForMessages returned before the timeout")
+	}
+}
@@ -0,0 +1,182 @@
+// Package comptest provides utilities for testing components in isolation,
+// without building a full orchestrator graph.
+package comptest
+
+import (
+	"comps/core"
+	"comps/core/clock"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// DefaultTimeout is the timeout used by the assertions in this package when
+// waiting for asynchronous activity.
+var DefaultTimeout = 5 * time.Second
+
+// Harness is a single running component, with its dependencies replaced by
+// FakeReferences (or by references supplied by the test).
+type Harness struct {
+	t      testing.TB
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Component is the running component.
+	Component core.Component
+
+	// Ref is a reference to the running component.
+	Ref core.ComponentReference
+
+	// Deps contains the references passed to the component's Start function.
+	Deps map[core.ComponentPath]core.ComponentReference
+
+	// Services contains the references available from the component's Host:
+	// a FakeReference for each service in its Requires, and none for each in
+	// its RequiresAll.
+	Services map[core.Service][]core.ComponentReference
+
+	// Clock is the fake clock handed to the component.  It starts at
+	// FakeEpoch and only moves when the test advances it.
+	Clock *clock.Fake
+}
+
+// FakeEpoch is the initial time of each Harness's Clock.
+var FakeEpoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Start starts a single component implementation.  Every path in the
+// implementation's Dependencies is given the corresponding reference from
+// deps, if present, or a new FakeReference otherwise.
+//
+// The component is stopped, and checked for leaks with AssertStops, when the
+// test completes, unless the test has already called Stop.
+func Start(t testing.TB, impl core.ComponentImpl, deps map[core.ComponentPath]core.ComponentReference) *Harness {
+	t.Helper()
+
+	allDeps := map[core.ComponentPath]core.ComponentReference{}
+	for _, path := range impl.Dependencies {
+		if ref, found := deps[path]; found {
+			allDeps[path] = ref
+		} else {
+			allDeps[path] = NewFakeReference()
+		}
+	}
+	for path := range deps {
+		if _, found := allDeps[path]; !found {
+			t.Fatalf("%s does not depend on %s", impl.Path, path)
+		}
+	}
+
+	services := map[core.Service][]core.ComponentReference{}
+	for _, service := range impl.Requires {
+		services[service] = []core.ComponentReference{NewFakeReference()}
+	}
+	for _, service := range impl.RequiresAll {
+		services[service] = []core.ComponentReference{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Harness{
+		t:        t,
+		ctx:      ctx,
+		cancel:   cancel,
+		Deps:     allDeps,
+		Services: services,
+		Clock:    clock.NewFake(FakeEpoch),
+	}
+
+	// the orchestrator is never started; it exists only to provide the Host
+	orch := core.NewOrchestrator(impl)
+	orch.SetClock(h.Clock)
+	h.Component = impl.Start(orch.Host(impl.Path, services), ctx, allDeps)
+	h.Ref = h.Component.NewReference()
+
+	t.Cleanup(func() {
+		if ctx.Err() == nil {
+			h.AssertStops(DefaultTimeout)
+		}
+	})
+
+	return h
+}
+
+// Fake returns the FakeReference for the given dependency, failing the test
+// if that dependency is not a FakeReference.
+func (h *Harness) Fake(path core.ComponentPath) *FakeReference {
+	h.t.Helper()
+
+	fake, ok := h.Deps[path].(*FakeReference)
+	if !ok {
+		h.t.Fatalf("dependency %s is not a *FakeReference", path)
+	}
+	return fake
+}
+
+// Context returns the context that was passed to the component's Start
+// function.
+func (h *Harness) Context() context.Context {
+	return h.ctx
+}
+
+// Stop cancels the component's context, without waiting for it to finish.
+func (h *Harness) Stop() {
+	h.cancel()
+}
+
+// AssertStops cancels the component's context and fails the test if the
+// component's Done channel does not close within the timeout.  This catches
+// components that leak goroutines or ignore their context.
+func (h *Harness) AssertStops(timeout time.Duration) {
+	h.t.Helper()
+
+	h.cancel()
+	select {
+	case <-h.Component.Done():
+	case <-time.After(timeout):
+		h.t.Errorf("component did not finish within %s of its context being cancelled", timeout)
+	}
+}
+
+// AssertMessages fails the test unless the fake has received exactly the
+// expected messages, in order.  It waits up to DefaultTimeout for the
+// messages to arrive.
+func AssertMessages(t testing.TB, fake *FakeReference, expected ...core.Message) {
+	t.Helper()
+
+	got := fake.WaitForMessages(len(expected), DefaultTimeout)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected messages:\n  got:      %s\n  expected: %s", formatMessages(got), formatMessages(expected))
+	}
+}
+
+// AssertMessageTypes fails the test unless the fake has received messages of
+// exactly the types of the given examples, in order.  This is useful when
+// messages contain values, such as functions, that cannot be compared.
+func AssertMessageTypes(t testing.TB, fake *FakeReference, examples ...core.Message) {
+	t.Helper()
+
+	got := fake.WaitForMessages(len(examples), DefaultTimeout)
+	gotTypes := make([]reflect.Type, len(got))
+	for i, msg := range got {
+		gotTypes[i] = reflect.TypeOf(msg)
+	}
+	expectedTypes := make([]reflect.Type, len(examples))
+	for i, msg := range examples {
+		expectedTypes[i] = reflect.TypeOf(msg)
+	}
+	if !reflect.DeepEqual(gotTypes, expectedTypes) {
+		t.Errorf("unexpected message types:\n  got:      %v\n  expected: %v", gotTypes, expectedTypes)
+	}
+}
+
+func formatMessages(msgs []core.Message) string {
+	rv := "["
+	for i, msg := range msgs {
+		if i > 0 {
+			rv += ", "
+		}
+		rv += fmt.Sprintf("%#v", msg)
+	}
+	return rv + "]"
+}
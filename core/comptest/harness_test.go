@@ -0,0 +1,142 @@
+package comptest_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// recordingT records failures, rather than failing the test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// forwarder is a component that forwards requests to its backend, and is
+// done when its context is cancelled, unless it is stuck.
+type forwarder struct {
+	backend core.ComponentReference
+	done    chan struct{}
+}
+
+func (f *forwarder) NewReference() core.ComponentReference { return f }
+func (f *forwarder) Done() <-chan struct{}                 { return f.done }
+
+func (f *forwarder) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	return f.backend.Request(ctx, msg)
+}
+
+func (f *forwarder) RequestAsync(ctx context.Context, msg core.Message) {
+	f.backend.RequestAsync(ctx, msg)
+}
+
+func forwarderImpl(stuck bool) core.ComponentImpl {
+	return core.ComponentImpl{
+		Path:         "forwarder",
+		Dependencies: []core.ComponentPath{"backend"},
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			f := &forwarder{backend: deps["backend"], done: make(chan struct{})}
+			deps["backend"].RequestAsync(ctx, "started")
+			if !stuck {
+				go func() {
+					<-ctx.Done()
+					close(f.done)
+				}()
+			}
+			return f
+		},
+	}
+}
+
+func TestHarnessFakesDependencies(t *testing.T) {
+	h := comptest.Start(t, forwarderImpl(false), nil)
+	backend := h.Fake("backend").Respond("pong", nil)
+
+	rsp, err := h.Ref.Request(context.Background(), "ping")
+	if rsp != "pong" || err != nil {
+		t.Fatalf("got %v, %v; want pong, nil", rsp, err)
+	}
+	comptest.AssertMessages(t, backend, "started", "ping")
+	comptest.AssertMessageTypes(t, backend, "", "")
+}
+
+func TestHarnessUsesGivenDependencies(t *testing.T) {
+	backend := comptest.NewFakeReference()
+	h := comptest.Start(t, forwarderImpl(false), map[core.ComponentPath]core.ComponentReference{"backend": backend})
+	if h.Deps["backend"] != backend {
+		t.Fatal("harness did not pass the given dependency")
+	}
+	comptest.AssertMessages(t, backend, "started")
+}
+
+func TestAssertStopsCatchesStuckComponent(t *testing.T) {
+	rt := &recordingT{TB: t}
+	h := comptest.Start(rt, forwarderImpl(true), nil)
+	h.AssertStops(10 * time.Millisecond)
+	if len(rt.errors) != 1 {
+		t.Fatalf("errors %v, want one for the stuck component", rt.errors)
+	}
+	if h.Context().Err() == nil {
+		t.Fatal("AssertStops did not cancel the component's context")
+	}
+}
+
+func TestAssertMessagesReportsDifferences(t *testing.T) {
+	rt := &recordingT{TB: t}
+	fake := comptest.NewFakeReference()
+	fake.Request(context.Background(), "a")
+	defer func(timeout time.Duration) { comptest.DefaultTimeout = timeout }(comptest.DefaultTimeout)
+	comptest.DefaultTimeout = 10 * time.Millisecond
+
+	comptest.AssertMessages(rt, fake, "b")
+	comptest.AssertMessageTypes(rt, fake, 1)
+	if len(rt.errors) != 2 {
+		t.Fatalf("errors %v, want two", rt.errors)
+	}
+}
+
+func TestHarnessHandsOutFakeClock(t *testing.T) {
+	var got interface{}
+	impl := core.ComponentImpl{
+		Path: "clocked",
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			got = host.Clock()
+			return &core.BaseComponent{}
+		},
+	}
+	h := comptest.Start(t, impl, nil)
+	if got != h.Clock {
+		t.Fatalf("component got clock %v, want the harness's fake clock", got)
+	}
+	if now := h.Clock.Now(); !now.Equal(comptest.FakeEpoch) {
+		t.Fatalf("fake clock starts at %v, want %v", now, comptest.FakeEpoch)
+	}
+}
+
+func TestHarnessFakesServices(t *testing.T) {
+	var logger core.ComponentReference
+	h := comptest.Start(t, core.ComponentImpl{
+		Path:     "comp/app.Main",
+		Requires: []core.Service{"logger"},
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			logger = host.Service("logger")
+			logger.Request(ctx, "started")
+			return &core.BaseComponent{}
+		},
+	}, nil)
+
+	fake := h.Services["logger"][0].(*comptest.FakeReference)
+	if logger != fake {
+		t.Fatal("Host did not hand out the harness's fake")
+	}
+	comptest.AssertMessages(t, fake, "started")
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Config is the configuration for a single component, in JSON form.  Each
+// component defines the structure of its own configuration, and decodes it
+// with Decode.
+type Config json.RawMessage
+
+// Decode decodes the configuration into v, which should be a pointer to a
+// struct containing the component's defaults.  Fields not present in the
+// configuration are left unchanged, and an empty configuration does nothing.
+func (c Config) Decode(v interface{}) error {
+	if len(c) == 0 {
+		return nil
+	}
+	return json.Unmarshal(c, v)
+}
+
+// Equal determines whether two configurations are the same, ignoring
+// whitespace.
+func (c Config) Equal(other Config) bool {
+	var a, b bytes.Buffer
+	if json.Compact(&a, c) != nil || json.Compact(&b, other) != nil {
+		return bytes.Equal(c, other)
+	}
+	return bytes.Equal(a.Bytes(), b.Bytes())
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(c).MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	return (*json.RawMessage)(c).UnmarshalJSON(data)
+}
+
+// ConfigSource loads the configuration for all components, keyed by component
+// path.  It is called when the orchestrator starts, and again each time it
+// is reconfigured.
+type ConfigSource func() (map[ComponentPath]Config, error)
+
+// FileConfigSource returns a ConfigSource that reads a JSON file containing
+// an object with a key for each configured component path, e.g.,
+//
+//	{"comp/listen.Main": {"address": "127.0.0.1:9000"}}
+func FileConfigSource(filename string) ConfigSource {
+	return func() (map[ComponentPath]Config, error) {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		config := map[ComponentPath]Config{}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("Parsing %s: %w", filename, err)
+		}
+		return config, nil
+	}
+}
+
+// SetConfigSource sets the source of component configuration.  This must be
+// called before Start.  Without a config source, every component has an empty
+// configuration.
+func (orch *Orchestrator) SetConfigSource(source ConfigSource) {
+	orch.configMu.Lock()
+	defer orch.configMu.Unlock()
+
+	orch.configSource = source
+}
+
+// Config returns the current configuration for the component with the given
+// path.  Components read their own with Host#Config, typically from their
+// Start function.
+func (orch *Orchestrator) Config(path ComponentPath) Config {
+	orch.configMu.Lock()
+	defer orch.configMu.Unlock()
+
+	return orch.config[path]
+}
+
+// loadConfig (re)loads the configuration from the config source, returning
+// the previous configuration.
+func (orch *Orchestrator) loadConfig() (map[ComponentPath]Config, error) {
+	orch.configMu.Lock()
+	source := orch.configSource
+	orch.configMu.Unlock()
+
+	if source == nil {
+		return map[ComponentPath]Config{}, nil
+	}
+	config, err := source()
+	if err != nil {
+		return nil, fmt.Errorf("Loading configuration: %w", err)
+	}
+
+	orch.configMu.Lock()
+	defer orch.configMu.Unlock()
+
+	old := orch.config
+	orch.config = config
+	return old, nil
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMessageDropped is the error recorded for messages discarded by a
+// mailbox's overflow policy.
+var ErrMessageDropped = errors.New("Message dropped by mailbox overflow policy")
+
+// DeadLetter describes an asynchronous request that could not be delivered
+// or handled.  The orchestrator sends these as messages to the component
+// registered with Orchestrator#HandleDeadLetters.
+type DeadLetter struct {
+	// Caller is the path of the component that sent the request.
+	Caller ComponentPath
+
+	// Target is the path of the component to which the request was sent.
+	Target ComponentPath
+
+	// Message is the message that failed.
+	Message Message
+
+	// Err is the error that occurred.
+	Err error
+
+	// Time is the time at which the failure was reported.
+	Time time.Time
+}
+
+type asyncFailureKey struct{}
+
+// withAsyncFailureHandler returns a context carrying a function to be called
+// when an asynchronous request sent with that context fails.
+func withAsyncFailureHandler(ctx context.Context, handler func(error)) context.Context {
+	return context.WithValue(ctx, asyncFailureKey{}, handler)
+}
+
+// ReportAsyncFailure reports that an asynchronous request, sent with the
+// given context, failed with the given error.  Components should call this
+// from RequestAsync (or from wherever they later handle the message) when the
+// error would otherwise be dropped.  If the request did not come through the
+// orchestrator, this does nothing.
+func ReportAsyncFailure(ctx context.Context, err error) {
+	if handler, ok := ctx.Value(asyncFailureKey{}).(func(error)); ok {
+		handler(err)
+	}
+}
@@ -0,0 +1,75 @@
+package core_test
+
+import (
+	"comps/core"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// letterSink records the dead letters sent to it.
+type letterSink struct {
+	core.BaseComponentReference
+	letters chan core.DeadLetter
+}
+
+func (s *letterSink) RequestAsync(ctx context.Context, msg core.Message) {
+	s.letters <- msg.(core.DeadLetter)
+}
+
+func TestFailedAsyncRequestsBecomeDeadLetters(t *testing.T) {
+	var ref core.ComponentReference
+	orch := core.NewOrchestrator(
+		core.ComponentImpl{
+			Path:         "root",
+			Dependencies: []core.ComponentPath{"target"},
+			Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+				ref = deps["target"]
+				return &core.BaseComponent{}
+			},
+		},
+		core.ComponentImpl{
+			Path: "target",
+			Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+				// BaseComponent rejects every request
+				return &core.BaseComponent{}
+			},
+		},
+	)
+	sink := &letterSink{letters: make(chan core.DeadLetter, 1)}
+	orch.HandleDeadLetters(sink)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer orch.Stop(context.Background())
+
+	ref.RequestAsync(context.Background(), "hello")
+	select {
+	case letter := <-sink.letters:
+		if letter.Caller != "root" || letter.Target != "target" || letter.Message != "hello" || letter.Err == nil {
+			t.Fatalf("unexpected dead letter %+v", letter)
+		}
+		if letter.Time.IsZero() {
+			t.Fatal("dead letter has no time")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no dead letter for a rejected async request")
+	}
+
+	// synchronous failures are returned to the caller instead
+	if _, err := ref.Request(context.Background(), "hello"); err == nil {
+		t.Fatal("rejected request succeeded")
+	}
+	select {
+	case letter := <-sink.letters:
+		t.Fatalf("dead letter %+v for a synchronous request", letter)
+	default:
+	}
+}
+
+func TestReportAsyncFailureWithoutOrchestrator(t *testing.T) {
+	// requests that did not come through the orchestrator have nowhere to
+	// report failures, which is not an error
+	core.ReportAsyncFailure(context.Background(), errors.New("ignored"))
+}
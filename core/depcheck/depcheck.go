@@ -0,0 +1,559 @@
+// Package depcheck defines an Analyzer that checks each core.ComponentImpl
+// literal against the way its Start function uses its dependencies.
+//
+// It reports:
+//
+//   - `deps[...]` keys in a Start function that are not listed in
+//     Dependencies (which would be a nil reference at runtime);
+//   - calls to helpers like logger.WrapMain(deps), which look up a fixed path in
+//     the deps map, when that path is not listed in Dependencies;
+//   - paths listed in Dependencies that the Start function never uses;
+//   - Timeouts, Retries, and Breakers keys that are not listed in
+//     Dependencies; and
+//   - component paths that do not match the package and variable containing
+//     the ComponentImpl (e.g., `comp/logger.Main` must be defined as `Main` in
+//     a package whose path ends with `comp/logger`).
+//
+// A dependency that is needed only so that the component is started, and is
+// deliberately never used, can be marked with a `depcheck:unused` comment on
+// the same line.
+package depcheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const doc = `check ComponentImpl Dependencies against use of the deps map
+
+Every deps[...] key, and every path looked up by a helper to which deps is
+passed, must be listed in Dependencies; every listed dependency must be used;
+every Timeouts, Retries, and Breakers key must be listed in Dependencies; and
+the component path must match its package and variable.`
+
+// Analyzer checks ComponentImpl literals.
+var Analyzer = &analysis.Analyzer{
+	Name:      "depcheck",
+	Doc:       doc,
+	Run:       run,
+	FactTypes: []analysis.Fact{new(usesPaths)},
+}
+
+// usesPaths is a fact about a function that takes a deps map and looks up
+// fixed paths in it, such as logger.WrapMain.
+type usesPaths struct {
+	Paths []string
+}
+
+// AFact implements analysis.Fact#AFact.
+func (*usesPaths) AFact() {}
+
+func (f *usesPaths) String() string {
+	return fmt.Sprintf("usesPaths(%s)", strings.Join(f.Paths, ", "))
+}
+
+// use is a single use of a path from a deps map
+type use struct {
+	path string
+	pos  token.Pos
+	// via is the name of the helper function through which the path was
+	// used, or empty for a direct index
+	via string
+}
+
+type checker struct {
+	pass *analysis.Pass
+
+	// varInits maps package-level variables to their initializers
+	varInits map[*types.Var]ast.Expr
+
+	// funcs maps package-level functions to their declarations
+	funcs map[*types.Func]*ast.FuncDecl
+
+	// helpers caches the paths used by package-level functions taking a deps
+	// map; nil entries are functions being computed (to break recursion)
+	helpers map[*types.Func]*usesPaths
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	c := &checker{
+		pass:     pass,
+		varInits: map[*types.Var]ast.Expr{},
+		funcs:    map[*types.Func]*ast.FuncDecl{},
+		helpers:  map[*types.Func]*usesPaths{},
+	}
+
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					vs, ok := spec.(*ast.ValueSpec)
+					if !ok || len(vs.Names) != len(vs.Values) {
+						continue
+					}
+					for i, name := range vs.Names {
+						if v, ok := pass.TypesInfo.Defs[name].(*types.Var); ok {
+							c.varInits[v] = vs.Values[i]
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func); ok {
+					c.funcs[fn] = decl
+				}
+			}
+		}
+	}
+
+	// export facts for helpers, so that dependent packages can see them
+	for fn := range c.funcs {
+		if fact := c.helperPaths(fn); fact != nil && len(fact.Paths) > 0 {
+			pass.ExportObjectFact(fn, fact)
+		}
+	}
+
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, value := range vs.Values {
+					lit, ok := value.(*ast.CompositeLit)
+					if !ok || !isCoreType(pass.TypesInfo.TypeOf(lit), "ComponentImpl") {
+						continue
+					}
+					varName := ""
+					if len(vs.Names) == len(vs.Values) {
+						varName = vs.Names[i].Name
+					}
+					c.checkImpl(file, lit, varName)
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// checkImpl checks a single ComponentImpl literal.
+func (c *checker) checkImpl(file *ast.File, lit *ast.CompositeLit, varName string) {
+	var pathExpr, depsExpr, startExpr ast.Expr
+	var policyExprs []*ast.KeyValueExpr
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		switch key.Name {
+		case "Path":
+			pathExpr = kv.Value
+		case "Dependencies":
+			depsExpr = kv.Value
+		case "Start":
+			startExpr = kv.Value
+		case "Timeouts", "Retries", "Breakers":
+			policyExprs = append(policyExprs, kv)
+		}
+	}
+
+	if pathExpr != nil {
+		if path, ok := c.eval(pathExpr, nil, 0); ok {
+			c.checkPath(pathExpr, path, varName)
+		}
+	}
+
+	// gather the declared dependencies
+	declared := map[string]ast.Expr{}
+	ignored := map[string]bool{}
+	declaredKnown := true
+	if depsLit, ok := depsExpr.(*ast.CompositeLit); ok {
+		for _, elt := range depsLit.Elts {
+			path, ok := c.eval(elt, nil, 0)
+			if !ok {
+				declaredKnown = false
+				continue
+			}
+			declared[path] = elt
+			if hasDirective(c.pass.Fset, file, elt, "depcheck:unused") {
+				ignored[path] = true
+			}
+		}
+	} else if depsExpr != nil {
+		declaredKnown = false
+	}
+
+	// per-dependency policies only apply to declared dependencies
+	for _, policy := range policyExprs {
+		policyLit, ok := policy.Value.(*ast.CompositeLit)
+		if !ok || !declaredKnown {
+			continue
+		}
+		for _, elt := range policyLit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			if path, ok := c.eval(kv.Key, nil, 0); ok {
+				if _, found := declared[path]; !found {
+					c.pass.Reportf(kv.Key.Pos(), "%s entry for %q, which is not listed in Dependencies", policy.Key.(*ast.Ident).Name, path)
+				}
+			}
+		}
+	}
+
+	// gather the uses in the Start function
+	var uses []use
+	escapes := false
+	switch start := startExpr.(type) {
+	case *ast.FuncLit:
+		uses, escapes = c.startUses(start.Type, start.Body)
+	case nil:
+		return
+	default:
+		// the Start function is not a literal; look for a local declaration
+		var fn *types.Func
+		if id, ok := start.(*ast.Ident); ok {
+			fn, _ = c.pass.TypesInfo.Uses[id].(*types.Func)
+		}
+		decl, found := c.funcs[fn]
+		if !found {
+			return
+		}
+		uses, escapes = c.startUses(decl.Type, decl.Body)
+	}
+
+	used := map[string]bool{}
+	for _, u := range uses {
+		used[u.path] = true
+		if _, found := declared[u.path]; found || !declaredKnown {
+			continue
+		}
+		if u.via != "" {
+			c.pass.Reportf(u.pos, "%s uses dependency %q, which is not listed in Dependencies", u.via, u.path)
+		} else {
+			c.pass.Reportf(u.pos, "dependency %q is not listed in Dependencies", u.path)
+		}
+	}
+
+	if escapes {
+		// the deps map is used in ways we cannot follow, so we cannot be
+		// sure that any dependency is unused
+		return
+	}
+	paths := make([]string, 0, len(declared))
+	for path := range declared {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if !used[path] && !ignored[path] {
+			c.pass.Reportf(declared[path].Pos(), "dependency %q is never used by Start (mark with `// depcheck:unused` if intended)", path)
+		}
+	}
+}
+
+// checkPath checks that a component path matches the package and variable
+// that define it.
+func (c *checker) checkPath(expr ast.Expr, path, varName string) {
+	pkgPart, name := "", path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		pkgPart, name = path[:i], path[i+1:]
+	}
+
+	if varName != "" && name != varName {
+		c.pass.Reportf(expr.Pos(), "component path %q does not match variable name %s", path, varName)
+	}
+
+	pkgPath := c.pass.Pkg.Path()
+	if pkgPart == "" {
+		if c.pass.Pkg.Name() != "main" {
+			c.pass.Reportf(expr.Pos(), "component path %q has no package part, but is not in package main", path)
+		}
+		return
+	}
+	if pkgPath != pkgPart && !strings.HasSuffix(pkgPath, "/"+pkgPart) {
+		c.pass.Reportf(expr.Pos(), "component path %q does not match package %s", path, pkgPath)
+	}
+}
+
+// startUses finds the uses of the deps parameter (the third parameter) in a
+// Start function.  It returns true for escapes if the deps map is used in
+// any way other than indexing it or passing it to a known helper.
+func (c *checker) startUses(ft *ast.FuncType, body *ast.BlockStmt) ([]use, bool) {
+	params := paramObjects(c.pass.TypesInfo, ft)
+	if len(params) < 3 || params[2] == nil {
+		return nil, false
+	}
+	return c.depsUses(params[2], body)
+}
+
+// depsUses finds the uses of the given deps map in the given body.
+func (c *checker) depsUses(deps types.Object, body *ast.BlockStmt) ([]use, bool) {
+	if body == nil {
+		return nil, true
+	}
+
+	var uses []use
+	escapes := false
+	handled := map[*ast.Ident]bool{}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			id, ok := n.X.(*ast.Ident)
+			if !ok || c.pass.TypesInfo.Uses[id] != deps {
+				return true
+			}
+			handled[id] = true
+			if path, ok := c.eval(n.Index, nil, 0); ok {
+				uses = append(uses, use{path: path, pos: n.Pos()})
+			} else {
+				escapes = true
+			}
+		case *ast.CallExpr:
+			for _, arg := range n.Args {
+				id, ok := arg.(*ast.Ident)
+				if !ok || c.pass.TypesInfo.Uses[id] != deps {
+					continue
+				}
+				handled[id] = true
+				fn := calledFunc(c.pass.TypesInfo, n)
+				fact := c.funcPaths(fn)
+				if fact == nil {
+					escapes = true
+					continue
+				}
+				for _, path := range fact.Paths {
+					uses = append(uses, use{path: path, pos: n.Pos(), via: fn.Pkg().Name() + "." + fn.Name()})
+				}
+			}
+		}
+		return true
+	})
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && !handled[id] && c.pass.TypesInfo.Uses[id] == deps {
+			escapes = true
+		}
+		return true
+	})
+
+	return uses, escapes
+}
+
+// funcPaths returns the paths used by a helper function, or nil if that is not
+// known.
+func (c *checker) funcPaths(fn *types.Func) *usesPaths {
+	if fn == nil {
+		return nil
+	}
+	if fn.Pkg() == c.pass.Pkg {
+		return c.helperPaths(fn)
+	}
+	fact := new(usesPaths)
+	if c.pass.ImportObjectFact(fn, fact) {
+		return fact
+	}
+	return nil
+}
+
+// helperPaths determines the paths a package-level function looks up in its
+// deps-map parameter.  It returns nil if the function has no such parameter,
+// or uses it in a way that cannot be followed.
+func (c *checker) helperPaths(fn *types.Func) *usesPaths {
+	if fact, found := c.helpers[fn]; found {
+		return fact
+	}
+	c.helpers[fn] = nil
+
+	decl := c.funcs[fn]
+	var depsParam types.Object
+	for _, param := range paramObjects(c.pass.TypesInfo, decl.Type) {
+		if param != nil && isDepsMap(param.Type()) {
+			depsParam = param
+			break
+		}
+	}
+	if depsParam == nil {
+		return nil
+	}
+
+	uses, escapes := c.depsUses(depsParam, decl.Body)
+	if escapes {
+		return nil
+	}
+	seen := map[string]bool{}
+	fact := &usesPaths{Paths: []string{}}
+	for _, u := range uses {
+		if !seen[u.path] {
+			seen[u.path] = true
+			fact.Paths = append(fact.Paths, u.path)
+		}
+	}
+	sort.Strings(fact.Paths)
+	c.helpers[fn] = fact
+	return fact
+}
+
+// maxEvalDepth limits recursion in eval
+const maxEvalDepth = 10
+
+// eval attempts to determine the constant string value of an expression.  It
+// follows package-level variables with constant initializers, conversions,
+// concatenation, and calls to package-level functions consisting of a single
+// return statement (with env giving the values of their parameters).
+func (c *checker) eval(expr ast.Expr, env map[types.Object]string, depth int) (string, bool) {
+	if depth > maxEvalDepth {
+		return "", false
+	}
+	info := c.pass.TypesInfo
+
+	if tv, ok := info.Types[expr]; ok && tv.Value != nil {
+		if tv.Value.Kind() == constant.String {
+			return constant.StringVal(tv.Value), true
+		}
+		return "", false
+	}
+
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return c.eval(e.X, env, depth+1)
+
+	case *ast.Ident:
+		obj := info.Uses[e]
+		if val, found := env[obj]; found {
+			return val, true
+		}
+		if v, ok := obj.(*types.Var); ok {
+			if init, found := c.varInits[v]; found {
+				return c.eval(init, nil, depth+1)
+			}
+		}
+
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", false
+		}
+		x, ok := c.eval(e.X, env, depth+1)
+		if !ok {
+			return "", false
+		}
+		y, ok := c.eval(e.Y, env, depth+1)
+		if !ok {
+			return "", false
+		}
+		return x + y, true
+
+	case *ast.CallExpr:
+		if tv, ok := info.Types[e.Fun]; ok && tv.IsType() && len(e.Args) == 1 {
+			return c.eval(e.Args[0], env, depth+1)
+		}
+		decl, found := c.funcs[calledFunc(info, e)]
+		if !found || decl.Body == nil || len(decl.Body.List) != 1 {
+			return "", false
+		}
+		ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			return "", false
+		}
+		params := paramObjects(info, decl.Type)
+		if len(params) != len(e.Args) {
+			return "", false
+		}
+		callEnv := map[types.Object]string{}
+		for i, param := range params {
+			if param == nil {
+				continue
+			}
+			val, ok := c.eval(e.Args[i], env, depth+1)
+			if !ok {
+				return "", false
+			}
+			callEnv[param] = val
+		}
+		return c.eval(ret.Results[0], callEnv, depth+1)
+	}
+
+	return "", false
+}
+
+// paramObjects returns the objects for a function's parameters, in order, with
+// nil for unnamed or blank parameters.
+func paramObjects(info *types.Info, ft *ast.FuncType) []types.Object {
+	var rv []types.Object
+	if ft.Params == nil {
+		return rv
+	}
+	for _, field := range ft.Params.List {
+		if len(field.Names) == 0 {
+			rv = append(rv, nil)
+			continue
+		}
+		for _, name := range field.Names {
+			rv = append(rv, info.Defs[name])
+		}
+	}
+	return rv
+}
+
+// calledFunc returns the function called by a call expression, or nil.
+func calledFunc(info *types.Info, call *ast.CallExpr) *types.Func {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		fn, _ := info.Uses[fun].(*types.Func)
+		return fn
+	case *ast.SelectorExpr:
+		fn, _ := info.Uses[fun.Sel].(*types.Func)
+		return fn
+	}
+	return nil
+}
+
+// isCoreType determines whether t is the named type from the core package.
+func isCoreType(t types.Type, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	if obj.Name() != name || obj.Pkg() == nil {
+		return false
+	}
+	path := obj.Pkg().Path()
+	return path == "core" || strings.HasSuffix(path, "/core")
+}
+
+// isDepsMap determines whether t is map[core.ComponentPath]core.ComponentReference.
+func isDepsMap(t types.Type) bool {
+	m, ok := t.Underlying().(*types.Map)
+	return ok && isCoreType(m.Key(), "ComponentPath") && isCoreType(m.Elem(), "ComponentReference")
+}
+
+// hasDirective determines whether a comment on the same line as the node
+// contains the given directive.
+func hasDirective(fset *token.FileSet, file *ast.File, node ast.Node, directive string) bool {
+	line := fset.Position(node.Pos()).Line
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if fset.Position(comment.Pos()).Line == line && strings.Contains(comment.Text, directive) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package depcheck_test
+
+import (
+	"comps/core/depcheck"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), depcheck.Analyzer, "comp/logger", "comp/app")
+}
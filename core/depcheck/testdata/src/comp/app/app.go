@@ -0,0 +1,66 @@
+package app
+
+import (
+	"comp/logger"
+	"context"
+	"core"
+	"time"
+)
+
+const prefix = "comp/"
+
+func path(name string) core.ComponentPath {
+	return core.ComponentPath(prefix + name + ".Main")
+}
+
+var Main = core.ComponentImpl{
+	Path: path("app"),
+	Dependencies: []core.ComponentPath{
+		"comp/logger.Main",
+		path("db"),        // want `dependency "comp/db.Main" is never used by Start`
+		"comp/cache.Main", // depcheck:unused
+		"comp/store.Main",
+	},
+	Timeouts: map[core.ComponentPath]time.Duration{
+		"comp/store.Main": time.Second,
+		"comp/other.Main": time.Second, // want `Timeouts entry for "comp/other.Main", which is not listed in Dependencies`
+	},
+	Breakers: map[core.ComponentPath]core.BreakerPolicy{
+		"comp/elsewhere.Main": {Failures: 3}, // want `Breakers entry for "comp/elsewhere.Main", which is not listed in Dependencies`
+	},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		logger.WrapMain(deps)
+		_ = deps["comp/store.Main"]
+		_ = deps["comp/missing.Main"] // want `dependency "comp/missing.Main" is not listed in Dependencies`
+		return nil
+	},
+}
+
+var UsesHelper = core.ComponentImpl{
+	Path:         "comp/app.UsesHelper",
+	Dependencies: []core.ComponentPath{},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		logger.WrapMain(deps) // want `logger.WrapMain uses dependency "comp/logger.Main", which is not listed in Dependencies`
+		return nil
+	},
+}
+
+var Escapes = core.ComponentImpl{
+	Path:         "comp/app.Escapes",
+	Dependencies: []core.ComponentPath{"comp/db.Main"},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		// the deps map escapes, so nothing is reported unused
+		keep(deps)
+		return nil
+	},
+}
+
+func keep(interface{}) {}
+
+var Misnamed = core.ComponentImpl{
+	Path: "comp/app.Other", // want `component path "comp/app.Other" does not match variable name Misnamed`
+}
+
+var Misplaced = core.ComponentImpl{
+	Path: "comp/elsewhere.Misplaced", // want `component path "comp/elsewhere.Misplaced" does not match package comp/app`
+}
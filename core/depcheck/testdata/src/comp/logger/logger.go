@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"context"
+	"core"
+)
+
+var componentPath core.ComponentPath = "comp/logger.Main"
+
+var Main = core.ComponentImpl{
+	Path:         componentPath,
+	Dependencies: []core.ComponentPath{},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		return nil
+	},
+}
+
+type MainClient struct{ wrapped core.ComponentReference }
+
+// WrapMain looks up this component in the deps map, so depcheck records a fact
+// for it.
+func WrapMain(deps map[core.ComponentPath]core.ComponentReference) MainClient { // want WrapMain:`usesPaths\(comp/logger.Main\)`
+	return MainClient{wrapped: deps[componentPath]}
+}
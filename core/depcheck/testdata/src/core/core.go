@@ -0,0 +1,32 @@
+// Package core is a stand-in for comps/core, with just enough for depcheck.
+package core
+
+import (
+	"context"
+	"time"
+)
+
+type ComponentPath string
+
+type Message interface{}
+
+type ComponentReference interface {
+	Request(ctx context.Context, msg Message) (Message, error)
+}
+
+type Component interface{}
+
+type Host interface{}
+
+type RetryPolicy struct{ MaxAttempts int }
+
+type BreakerPolicy struct{ Failures int }
+
+type ComponentImpl struct {
+	Path         ComponentPath
+	Dependencies []ComponentPath
+	Start        func(Host, context.Context, map[ComponentPath]ComponentReference) Component
+	Timeouts     map[ComponentPath]time.Duration
+	Retries      map[ComponentPath]RetryPolicy
+	Breakers     map[ComponentPath]BreakerPolicy
+}
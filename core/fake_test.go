@@ -0,0 +1,61 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeComponent is a component whose references are a FakeReference, and
+// which is done when its context is cancelled.
+type fakeComponent struct {
+	fake *comptest.FakeReference
+	done <-chan struct{}
+}
+
+func (c *fakeComponent) NewReference() core.ComponentReference { return c.fake }
+func (c *fakeComponent) Done() <-chan struct{}                 { return c.done }
+
+// fakeImpl returns an implementation of a fakeComponent, with the given
+// dependencies, that handles requests with fake.  If start is not nil, it is
+// called from Start with the component's context and dependencies.
+func fakeImpl(path core.ComponentPath, fake *comptest.FakeReference, deps []core.ComponentPath,
+	start func(context.Context, map[core.ComponentPath]core.ComponentReference)) core.ComponentImpl {
+	return core.ComponentImpl{
+		Path:         path,
+		Dependencies: deps,
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			if start != nil {
+				start(ctx, deps)
+			}
+			return &fakeComponent{fake: fake, done: ctx.Done()}
+		},
+	}
+}
+
+// stop stops the orchestrator, failing the test if that takes too long.
+func stop(t *testing.T, orch *core.Orchestrator) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), comptest.DefaultTimeout)
+	defer cancel()
+	if err := orch.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %s", err)
+	}
+}
+
+// eventually polls cond until it returns true, failing the test after
+// comptest.DefaultTimeout.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(comptest.DefaultTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// Future is the eventual result of a request made with RequestFuture.
+type Future struct {
+	done   chan struct{}
+	result Message
+	err    error
+}
+
+// RequestFuture sends a message to the given reference, without waiting for
+// the response, and returns a Future which will contain the response.  This
+// works with any ComponentReference, as it simply calls Request in a new
+// goroutine.  The context applies to the request, as for Request.
+func RequestFuture(ctx context.Context, ref ComponentReference, msg Message) *Future {
+	f := &Future{done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		f.result, f.err = ref.Request(ctx, msg)
+	}()
+	return f
+}
+
+// Done returns a channel which closes when the response is available.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns nil if the request is not yet complete; otherwise it returns
+// the error from the request, if any.
+func (f *Future) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
+
+// Wait waits for the request to complete and returns its result.  If the
+// context is cancelled first, it returns the context's error; the request
+// itself continues.
+func (f *Future) Wait(ctx context.Context) (Message, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WaitAll waits for all of the given futures to complete, returning their
+// results in the same order.  Futures are waited for concurrently, and as
+// soon as any one fails, WaitAll returns its error without waiting for the
+// rest.  If the context is cancelled first, it returns the context's error.
+func WaitAll(ctx context.Context, futures ...*Future) ([]Message, error) {
+	results := make([]Message, len(futures))
+	pending := append([]*Future{}, futures...)
+	index := make([]int, len(futures))
+	for i := range index {
+		index[i] = i
+	}
+	for len(pending) > 0 {
+		i, result, err := WaitAny(ctx, pending...)
+		if err != nil {
+			return nil, err
+		}
+		results[index[i]] = result
+		pending = append(pending[:i], pending[i+1:]...)
+		index = append(index[:i], index[i+1:]...)
+	}
+	return results, nil
+}
+
+// WaitAny waits for any of the given futures to complete, returning the index
+// of that future along with its result.  If the context is cancelled first, it
+// returns -1 and the context's error.
+func WaitAny(ctx context.Context, futures ...*Future) (int, Message, error) {
+	if len(futures) == 0 {
+		return -1, nil, errors.New("WaitAny requires at least one future")
+	}
+
+	cases := make([]reflect.SelectCase, len(futures)+1)
+	for i, f := range futures {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.done)}
+	}
+	cases[len(futures)] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == len(futures) {
+		return -1, nil, ctx.Err()
+	}
+	f := futures[chosen]
+	return chosen, f.result, f.err
+}
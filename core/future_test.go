@@ -0,0 +1,128 @@
+package core_test
+
+import (
+	"comps/core"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// gated is a reference whose requests for a key wait until that key's
+// channel is closed, then return the key, or fail if the key is "fail".
+type gated map[string]chan struct{}
+
+func (g gated) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	key := msg.(string)
+	select {
+	case <-g[key]:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if key == "fail" {
+		return nil, errors.New("failed")
+	}
+	return key, nil
+}
+
+func (g gated) RequestAsync(ctx context.Context, msg core.Message) {}
+
+func newGated(keys ...string) gated {
+	g := gated{}
+	for _, key := range keys {
+		g[key] = make(chan struct{})
+	}
+	return g
+}
+
+func TestFutureWait(t *testing.T) {
+	g := newGated("a")
+	f := core.RequestFuture(context.Background(), g, "a")
+	if err := f.Err(); err != nil {
+		t.Fatalf("Err before completion is %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait with a cancelled context returned %v", err)
+	}
+
+	close(g["a"])
+	<-f.Done()
+	if rsp, err := f.Wait(context.Background()); rsp != "a" || err != nil {
+		t.Fatalf("Wait returned %v, %v; want a, nil", rsp, err)
+	}
+}
+
+func TestWaitAllReturnsResultsInOrder(t *testing.T) {
+	g := newGated("a", "b")
+	fa := core.RequestFuture(context.Background(), g, "a")
+	fb := core.RequestFuture(context.Background(), g, "b")
+	close(g["b"])
+	close(g["a"])
+
+	results, err := core.WaitAll(context.Background(), fa, fb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []core.Message{"a", "b"}) {
+		t.Fatalf("results %v, want [a b]", results)
+	}
+}
+
+func TestWaitAllFails(t *testing.T) {
+	g := newGated("a", "fail")
+	fa := core.RequestFuture(context.Background(), g, "a")
+	ffail := core.RequestFuture(context.Background(), g, "fail")
+	close(g["a"])
+	close(g["fail"])
+
+	if _, err := core.WaitAll(context.Background(), fa, ffail); err == nil || err.Error() != "failed" {
+		t.Fatalf("WaitAll returned %v, want the failure", err)
+	}
+}
+
+func TestWaitAllReturnsEarliestFailure(t *testing.T) {
+	g := newGated("a", "fail")
+	fa := core.RequestFuture(context.Background(), g, "a")
+	ffail := core.RequestFuture(context.Background(), g, "fail")
+	defer close(g["a"])
+	close(g["fail"])
+
+	// a never completes while WaitAll runs, so it must not wait for it
+	if _, err := core.WaitAll(context.Background(), fa, ffail); err == nil || err.Error() != "failed" {
+		t.Fatalf("WaitAll returned %v, want the failure", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := core.WaitAll(ctx, fa); err != context.Canceled {
+		t.Fatalf("WaitAll with a cancelled context returned %v", err)
+	}
+	if results, err := core.WaitAll(ctx); len(results) != 0 || err != nil {
+		t.Fatalf("WaitAll with no futures returned %v, %v", results, err)
+	}
+}
+
+func TestWaitAny(t *testing.T) {
+	g := newGated("a", "b")
+	fa := core.RequestFuture(context.Background(), g, "a")
+	fb := core.RequestFuture(context.Background(), g, "b")
+	close(g["b"])
+
+	i, rsp, err := core.WaitAny(context.Background(), fa, fb)
+	if i != 1 || rsp != "b" || err != nil {
+		t.Fatalf("WaitAny returned %d, %v, %v; want 1, b, nil", i, rsp, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if i, _, err := core.WaitAny(ctx, fa); i != -1 || err != context.Canceled {
+		t.Fatalf("WaitAny with a cancelled context returned %d, %v", i, err)
+	}
+	if _, _, err := core.WaitAny(context.Background()); err == nil {
+		t.Fatal("WaitAny with no futures succeeded")
+	}
+	close(g["a"])
+}
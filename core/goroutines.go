@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// componentLabel is the pprof label identifying the component that owns a
+// goroutine.  The orchestrator runs each component's Start function, and each
+// request made through the references it hands out, under this label, so
+// goroutines started from either are attributed to the component.  After a
+// request, the calling goroutine is labeled as owned by the caller again.
+const componentLabel = "component"
+
+// withComponentLabel calls f with the calling goroutine labeled as owned by
+// the given component.
+//
+// A goroutine's own labels cannot be read, and pprof.Do would restore those of
+// ctx when f returns, which for a request are often none (e.g., with
+// context.Background()).  Instead, when f returns, the goroutine is labeled
+// with those of ctx and as owned by owner, the component on whose behalf it
+// runs, or with just those of ctx if owner is empty.
+func withComponentLabel(ctx context.Context, path, owner ComponentPath, f func(context.Context)) {
+	restore := ctx
+	if owner != "" {
+		restore = pprof.WithLabels(ctx, pprof.Labels(componentLabel, string(owner)))
+	}
+	defer pprof.SetGoroutineLabels(restore)
+
+	labeled := pprof.WithLabels(ctx, pprof.Labels(componentLabel, string(path)))
+	pprof.SetGoroutineLabels(labeled)
+	f(labeled)
+}
+
+// GoroutineGroup describes a set of goroutines with the same stack and owner.
+type GoroutineGroup struct {
+	// Component is the component owning these goroutines, or empty if they
+	// are not owned by a component.
+	Component ComponentPath
+
+	// Count is the number of goroutines in this group.
+	Count int
+
+	// Stack is the goroutines' stack, one frame per line, innermost first.
+	Stack string
+}
+
+// componentLabelRegexp matches the component label in the labels of a
+// goroutine profile
+var componentLabelRegexp = regexp.MustCompile(`"` + componentLabel + `":("(?:[^"\\]|\\.)*")`)
+
+// Goroutines returns all current goroutines, grouped by owning component and
+// stack.  Groups are sorted by component, and then by decreasing count.
+func Goroutines() []GoroutineGroup {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+
+	// the profile contains a header line and then a paragraph for each
+	// group, beginning with "<count> @ <pcs>", followed by an optional
+	// "# labels: {..}" line and a "#\t<pc>\t<func>\t<file:line>" line per
+	// frame
+	groups := []GoroutineGroup{}
+	for _, para := range strings.Split(buf.String(), "\n\n") {
+		lines := strings.Split(strings.TrimSpace(para), "\n")
+		count, err := strconv.Atoi(strings.SplitN(lines[0], " ", 2)[0])
+		if err != nil {
+			continue
+		}
+		group := GoroutineGroup{Count: count}
+		frames := []string{}
+		for _, line := range lines[1:] {
+			if strings.HasPrefix(line, "# labels:") {
+				if m := componentLabelRegexp.FindStringSubmatch(line); m != nil {
+					if path, err := strconv.Unquote(m[1]); err == nil {
+						group.Component = ComponentPath(path)
+					}
+				}
+				continue
+			}
+			fields := strings.Split(strings.TrimPrefix(line, "#"), "\t")
+			if len(fields) == 4 {
+				frames = append(frames, fields[2]+" "+strings.TrimSpace(fields[3]))
+			}
+		}
+		group.Stack = strings.Join(frames, "\n")
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Component != groups[j].Component {
+			return groups[i].Component < groups[j].Component
+		}
+		return groups[i].Count > groups[j].Count
+	})
+	return groups
+}
+
+// LeakedGoroutines returns the number of goroutines still running for each
+// stopped component, keyed by component path.  After Stop returns, any
+// goroutines that remain indicate a leak in that component.
+func (orch *Orchestrator) LeakedGoroutines() map[ComponentPath]int {
+	orch.mu.Lock()
+	stopped := map[ComponentPath]bool{}
+	for path, acomp := range orch.active {
+		stopped[path] = acomp.state == StoppedState
+	}
+	orch.mu.Unlock()
+
+	leaks := map[ComponentPath]int{}
+	for _, group := range Goroutines() {
+		if stopped[group.Component] {
+			leaks[group.Component] += group.Count
+		}
+	}
+	return leaks
+}
@@ -0,0 +1,92 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"strings"
+	"testing"
+)
+
+// leak blocks until release is closed, standing in for a goroutine that a
+// component forgets to stop.
+func leak(release, exited chan struct{}) {
+	<-release
+	close(exited)
+}
+
+func TestGoroutinesLabeledByComponent(t *testing.T) {
+	release, exited := make(chan struct{}), make(chan struct{})
+	defer func() {
+		close(release)
+		<-exited
+	}()
+
+	orch := core.NewOrchestrator(
+		fakeImpl("leaky", comptest.NewFakeReference(), []core.ComponentPath{"tidy"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				go leak(release, exited)
+			}),
+		fakeImpl("tidy", comptest.NewFakeReference(), nil, nil),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// leak is labeled as soon as it starts running
+	var group core.GoroutineGroup
+	eventually(t, "leak goroutine", func() bool {
+		for _, group = range core.Goroutines() {
+			if strings.Contains(group.Stack, "core_test.leak") {
+				return true
+			}
+		}
+		return false
+	})
+	if group.Component != "leaky" || group.Count != 1 {
+		t.Errorf("leak goroutine group %+v, want one owned by leaky", group)
+	}
+
+	stop(t, orch)
+	leaks := orch.LeakedGoroutines()
+	if leaks["leaky"] != 1 || leaks["tidy"] != 0 {
+		t.Fatalf("leaks %v, want one from leaky", leaks)
+	}
+}
+
+// requestThenBlock makes a request without any pprof labels in its context,
+// and then blocks, so its goroutine can be found in a profile.
+func requestThenBlock(ref core.ComponentReference, requested, release chan struct{}) {
+	ref.Request(context.Background(), "ping")
+	close(requested)
+	<-release
+}
+
+func TestComponentLabelSurvivesRequest(t *testing.T) {
+	requested := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	orch := core.NewOrchestrator(
+		fakeImpl("caller", comptest.NewFakeReference(), []core.ComponentPath{"target"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				go requestThenBlock(deps["target"], requested, release)
+			}),
+		fakeImpl("target", comptest.NewFakeReference(), nil, nil),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+	<-requested
+
+	for _, group := range core.Goroutines() {
+		if strings.Contains(group.Stack, "requestThenBlock") {
+			if group.Component != "caller" {
+				t.Fatalf("goroutine owned by %q after request, want \"caller\"", group.Component)
+			}
+			return
+		}
+	}
+	t.Fatal("requestThenBlock goroutine not found")
+}
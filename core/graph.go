@@ -0,0 +1,134 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph describes the dependency graph of the active components, as returned
+// from Orchestrator#Graph.
+type Graph struct {
+	// Root is the path of the root component.
+	Root ComponentPath `json:"root"`
+
+	// Nodes contains the active components, sorted by path.
+	Nodes []GraphNode `json:"nodes"`
+
+	// Edges contains the dependencies between active components, sorted by
+	// From and then To.
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a component in a Graph.
+type GraphNode struct {
+	// Path is the component's path.
+	Path ComponentPath `json:"path"`
+
+	// State is the component's current state.
+	State ComponentState `json:"state"`
+}
+
+// GraphEdge is a dependency in a Graph.
+type GraphEdge struct {
+	// From is the path of the dependent component.
+	From ComponentPath `json:"from"`
+
+	// To is the path of the component on which From depends.
+	To ComponentPath `json:"to"`
+
+	// Dynamic is true if From acquired the dependency at runtime, with
+	// Lookup, rather than listing it in its Dependencies.
+	Dynamic bool `json:"dynamic,omitempty"`
+
+	// Service is the service that From requires and To provides, if From
+	// depends on To for that service rather than listing it in its
+	// Dependencies.
+	Service Service `json:"service,omitempty"`
+}
+
+// Graph returns the dependency graph of the active components, including
+// their states.
+func (orch *Orchestrator) Graph() Graph {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	g := Graph{
+		Root:  orch.RootPath,
+		Nodes: []GraphNode{},
+		Edges: []GraphEdge{},
+	}
+	for path, acomp := range orch.active {
+		g.Nodes = append(g.Nodes, GraphNode{Path: path, State: acomp.state})
+		for _, dep := range orch.registered[path].Dependencies {
+			g.Edges = append(g.Edges, GraphEdge{From: path, To: dep})
+		}
+		g.Edges = append(g.Edges, orch.implicitDependencies(path)...)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].Path < g.Nodes[j].Path
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// GraphDOT returns the dependency graph of the active components in Graphviz
+// DOT format.  Nodes are colored by state.
+func (orch *Orchestrator) GraphDOT() string {
+	return orch.Graph().DOT()
+}
+
+// GraphJSON returns the dependency graph of the active components as JSON.
+func (orch *Orchestrator) GraphJSON() ([]byte, error) {
+	return json.MarshalIndent(orch.Graph(), "", "  ")
+}
+
+// DOT formats the graph in Graphviz DOT format.  Nodes are colored by state,
+// dynamic edges are dashed, and service edges are labelled with the service.
+func (g Graph) DOT() string {
+	lines := []string{
+		"digraph components {",
+		"  node [shape=box, style=filled];",
+	}
+	for _, node := range g.Nodes {
+		attrs := fmt.Sprintf("fillcolor=%q, tooltip=%q", StateColor(node.State), string(node.State))
+		if node.Path == g.Root {
+			attrs += ", penwidth=2"
+		}
+		lines = append(lines, fmt.Sprintf("  %q [%s];", string(node.Path), attrs))
+	}
+	for _, edge := range g.Edges {
+		attrs := ""
+		if edge.Dynamic {
+			attrs = " [style=dashed]"
+		} else if edge.Service != "" {
+			attrs = fmt.Sprintf(" [label=%q]", string(edge.Service))
+		}
+		lines = append(lines, fmt.Sprintf("  %q -> %q%s;", string(edge.From), string(edge.To), attrs))
+	}
+	lines = append(lines, "}", "")
+	return strings.Join(lines, "\n")
+}
+
+// StateColor returns the color used to represent a component state in
+// graphs, as an HTML color.
+func StateColor(state ComponentState) string {
+	switch state {
+	case RunningState:
+		return "#c8e6c9"
+	case StoppingState:
+		return "#fff9c4"
+	case StoppedState:
+		return "#e0e0e0"
+	case FailedState:
+		return "#ef9a9a"
+	default:
+		return "#ffcdd2"
+	}
+}
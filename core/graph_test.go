@@ -0,0 +1,79 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// startDiamond starts root, which depends on a and b, where a also depends
+// on b.
+func startDiamond(t *testing.T) *core.Orchestrator {
+	t.Helper()
+
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"a", "b"}, nil),
+		fakeImpl("a", comptest.NewFakeReference(), []core.ComponentPath{"b"}, nil),
+		fakeImpl("b", comptest.NewFakeReference(), nil, nil),
+		fakeImpl("unused", comptest.NewFakeReference(), nil, nil),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+	return orch
+}
+
+func TestGraph(t *testing.T) {
+	g := startDiamond(t).Graph()
+
+	want := core.Graph{
+		Root: "root",
+		Nodes: []core.GraphNode{
+			{Path: "a", State: core.RunningState},
+			{Path: "b", State: core.RunningState},
+			{Path: "root", State: core.RunningState},
+		},
+		Edges: []core.GraphEdge{
+			{From: "a", To: "b"},
+			{From: "root", To: "a"},
+			{From: "root", To: "b"},
+		},
+	}
+	if !reflect.DeepEqual(g, want) {
+		t.Fatalf("graph %+v, want %+v", g, want)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	want := `digraph components {
+  node [shape=box, style=filled];
+  "a" [fillcolor="#c8e6c9", tooltip="running"];
+  "b" [fillcolor="#c8e6c9", tooltip="running"];
+  "root" [fillcolor="#c8e6c9", tooltip="running", penwidth=2];
+  "a" -> "b";
+  "root" -> "a";
+  "root" -> "b";
+}
+`
+	if got := startDiamond(t).GraphDOT(); got != want {
+		t.Fatalf("DOT:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGraphJSON(t *testing.T) {
+	orch := startDiamond(t)
+	data, err := orch.GraphJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g core.Graph
+	if err := json.Unmarshal(data, &g); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, orch.Graph()) {
+		t.Fatalf("JSON round trip gave %+v, want %+v", g, orch.Graph())
+	}
+}
@@ -0,0 +1,317 @@
+package core
+
+import (
+	"comps/core/clock"
+	"context"
+	"fmt"
+)
+
+// Host is passed to a component's Start function, and gives the component
+// access to the orchestrator's services for that component.  Beyond its own
+// configuration, snapshot, and the like, a component can only reach the
+// orchestrator through the capabilities it lists in
+// ComponentImpl#Capabilities.
+type Host interface {
+	// Path returns the component's path.
+	Path() ComponentPath
+
+	// Clock returns the orchestrator's clock.
+	Clock() clock.Clock
+
+	// Config returns the component's current configuration.
+	Config() Config
+
+	// Snapshot returns the component's most recent snapshot, or nil if there
+	// is none (see Snapshotter).
+	Snapshot() []byte
+
+	// Handoff returns the state handed off by the instance being replaced, or
+	// nil if there is none (see HandoffComponent).
+	Handoff() interface{}
+
+	// Service returns a reference to the provider of a service listed in
+	// ComponentImpl#Requires.  It panics for any other service.
+	Service(service Service) ComponentReference
+
+	// Services returns references to all providers of a service listed in
+	// ComponentImpl#RequiresAll, in order of path.  It panics for any other
+	// service.
+	Services(service Service) []ComponentReference
+
+	// Status returns the StatusCapability.  It panics if the component did not
+	// request that capability.
+	Status() StatusReader
+
+	// Lifecycle returns the LifecycleCapability.  It panics if the component
+	// did not request that capability.
+	Lifecycle() LifecycleController
+
+	// Lookup returns the LookupCapability.  It panics if the component did not
+	// request that capability.
+	Lookup() Lookup
+
+	// Sinks returns the SinkCapability.  It panics if the component did not
+	// request that capability.
+	Sinks() SinkRegistry
+}
+
+// Capability names a power over the orchestrator that a component may request
+// in ComponentImpl#Capabilities.  It is one of the *Capability constants.
+type Capability string
+
+// Capability values
+const (
+	// StatusCapability allows reading the status and graph of all components
+	// (StatusReader).
+	StatusCapability Capability = "status"
+
+	// LifecycleCapability allows shutting down, reconfiguring, and replacing
+	// components (LifecycleController).
+	LifecycleCapability Capability = "lifecycle"
+
+	// LookupCapability allows finding components that are not among the
+	// component's Dependencies (Lookup).
+	LookupCapability Capability = "lookup"
+
+	// SinkCapability allows receiving dead letters and lifecycle events
+	// (SinkRegistry).
+	SinkCapability Capability = "sinks"
+)
+
+// StatusReader reads the state of the orchestrator's components.
+type StatusReader interface {
+	// Status is Orchestrator#Status.
+	Status() map[ComponentPath]ComponentStatus
+
+	// Graph is Orchestrator#Graph.
+	Graph() Graph
+
+	// GraphDOT is Orchestrator#GraphDOT.
+	GraphDOT() string
+
+	// GraphJSON is Orchestrator#GraphJSON.
+	GraphJSON() ([]byte, error)
+}
+
+// LifecycleController changes the set of running components.
+type LifecycleController interface {
+	// RequestShutdown is Orchestrator#RequestShutdown.
+	RequestShutdown(err error)
+
+	// Reconfigure is Orchestrator#Reconfigure.  This must not be called from
+	// a Start function, or while handling a request from a Start function.
+	Reconfigure(ctx context.Context) ([]ReconfigureStep, error)
+
+	// Replace is Orchestrator#Replace, with the same restrictions as
+	// Reconfigure.
+	Replace(ctx context.Context, newImpl ComponentImpl) error
+}
+
+// Lookup finds running components at runtime.
+type Lookup interface {
+	// Lookup returns a reference to the running component with the given
+	// path.  Requests through it carry the caller's identity, and are
+	// subject to the target's AccessPolicy, like those through the
+	// references passed to Start.
+	//
+	// Each lookup is recorded as a dynamic dependency of the caller: it
+	// appears in the graph, and the caller is stopped before the target, and
+	// restarted along with it.  Lookups fail for components that are not
+	// registered or not running, and for those that depend on the caller,
+	// which would form a cycle.
+	//
+	// This must not be called from a Start function.
+	Lookup(path ComponentPath) (ComponentReference, error)
+}
+
+// SinkRegistry registers components to receive messages from the
+// orchestrator itself.
+type SinkRegistry interface {
+	// HandleDeadLetters is Orchestrator#HandleDeadLetters.
+	HandleDeadLetters(ref ComponentReference)
+
+	// HandleLifecycleEvents is Orchestrator#HandleLifecycleEvents.
+	HandleLifecycleEvents(ref ComponentReference)
+}
+
+// Host returns the Host for the component with the given path, with the
+// capabilities its registered implementation requests, and the given
+// references to the providers of the services it requires.  The orchestrator
+// passes a Host to each component's Start function; this is exported for
+// tests and harnesses that start components themselves.
+func (orch *Orchestrator) Host(path ComponentPath, services map[Service][]ComponentReference) Host {
+	orch.mu.Lock()
+	capabilities := orch.registered[path].Capabilities
+	orch.mu.Unlock()
+
+	return orch.host(path, capabilities, services)
+}
+
+// host returns a Host with the given capabilities and services.
+func (orch *Orchestrator) host(path ComponentPath, capabilities []Capability, services map[Service][]ComponentReference) Host {
+	h := &host{
+		orch:         orch,
+		path:         path,
+		capabilities: map[Capability]bool{},
+		services:     services,
+	}
+	for _, c := range capabilities {
+		h.capabilities[c] = true
+	}
+	return h
+}
+
+// host implements Host.  The capabilities it returns are separate types
+// wrapping the orchestrator, so that a component cannot reach other
+// capabilities with a type assertion.
+type host struct {
+	orch         *Orchestrator
+	path         ComponentPath
+	capabilities map[Capability]bool
+	services     map[Service][]ComponentReference
+}
+
+var _ Host = &host{}
+
+func (h *host) Path() ComponentPath  { return h.path }
+func (h *host) Clock() clock.Clock   { return h.orch.Clock() }
+func (h *host) Config() Config       { return h.orch.Config(h.path) }
+func (h *host) Snapshot() []byte     { return h.orch.Snapshot(h.path) }
+func (h *host) Handoff() interface{} { return h.orch.Handoff(h.path) }
+
+func (h *host) Service(service Service) ComponentReference {
+	refs, found := h.services[service]
+	if !found || len(refs) != 1 {
+		panic(fmt.Sprintf("Component %s does not require the %q service", h.path, service))
+	}
+	return refs[0]
+}
+
+func (h *host) Services(service Service) []ComponentReference {
+	refs, found := h.services[service]
+	if !found {
+		panic(fmt.Sprintf("Component %s does not require all providers of the %q service", h.path, service))
+	}
+	return refs
+}
+
+func (h *host) Status() StatusReader {
+	h.require(StatusCapability)
+	return statusReader{h.orch}
+}
+
+func (h *host) Lifecycle() LifecycleController {
+	h.require(LifecycleCapability)
+	return lifecycleController{h.orch}
+}
+
+func (h *host) Lookup() Lookup {
+	h.require(LookupCapability)
+	return lookup{h.orch, h.path}
+}
+
+func (h *host) Sinks() SinkRegistry {
+	h.require(SinkCapability)
+	return sinkRegistry{h.orch}
+}
+
+// require panics if the component did not request the given capability.
+func (h *host) require(c Capability) {
+	if !h.capabilities[c] {
+		panic(fmt.Sprintf("Component %s did not request the %q capability", h.path, c))
+	}
+}
+
+type statusReader struct{ orch *Orchestrator }
+
+func (s statusReader) Status() map[ComponentPath]ComponentStatus { return s.orch.Status() }
+func (s statusReader) Graph() Graph                              { return s.orch.Graph() }
+func (s statusReader) GraphDOT() string                          { return s.orch.GraphDOT() }
+func (s statusReader) GraphJSON() ([]byte, error)                { return s.orch.GraphJSON() }
+
+type lifecycleController struct{ orch *Orchestrator }
+
+func (l lifecycleController) RequestShutdown(err error) { l.orch.RequestShutdown(err) }
+
+func (l lifecycleController) Reconfigure(ctx context.Context) ([]ReconfigureStep, error) {
+	return l.orch.Reconfigure(ctx)
+}
+
+func (l lifecycleController) Replace(ctx context.Context, newImpl ComponentImpl) error {
+	return l.orch.Replace(ctx, newImpl)
+}
+
+type lookup struct {
+	orch   *Orchestrator
+	caller ComponentPath
+}
+
+func (l lookup) Lookup(path ComponentPath) (ComponentReference, error) {
+	return l.orch.lookup(l.caller, path)
+}
+
+type sinkRegistry struct{ orch *Orchestrator }
+
+func (s sinkRegistry) HandleDeadLetters(ref ComponentReference) {
+	s.orch.HandleDeadLetters(ref)
+}
+
+func (s sinkRegistry) HandleLifecycleEvents(ref ComponentReference) {
+	s.orch.HandleLifecycleEvents(ref)
+}
+
+// lookup returns a reference from caller to the running component with the
+// given path, recording the dependency so that the caller is stopped (and
+// restarted) before the target.
+func (orch *Orchestrator) lookup(caller, path ComponentPath) (ComponentReference, error) {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	if _, found := orch.registered[path]; !found {
+		return nil, fmt.Errorf("Lookup of %s by %s: no component with that path", path, caller)
+	}
+	acomp, found := orch.active[path]
+	if !found {
+		return nil, fmt.Errorf("Lookup of %s by %s: component is not running", path, caller)
+	}
+	if acomp.state != RunningState {
+		return nil, fmt.Errorf("Lookup of %s by %s: component is %s", path, caller, acomp.state)
+	}
+	if orch.dependsOn(path, caller) {
+		return nil, fmt.Errorf("Lookup of %s by %s: dependency cycle", path, caller)
+	}
+
+	if orch.dynamic[caller] == nil {
+		orch.dynamic[caller] = map[ComponentPath]bool{}
+	}
+	orch.dynamic[caller][path] = true
+	return &reference{
+		orch:    orch,
+		caller:  caller,
+		target:  path,
+		binding: orch.binding(path),
+	}, nil
+}
+
+// dependsOn determines whether from is, or depends directly or indirectly on,
+// the active component to.  This assumes that orch.mu is held.
+func (orch *Orchestrator) dependsOn(from, to ComponentPath) bool {
+	seen := map[ComponentPath]bool{}
+	var recur func(path ComponentPath) bool
+	recur = func(path ComponentPath) bool {
+		if path == to {
+			return true
+		}
+		if seen[path] {
+			return false
+		}
+		seen[path] = true
+		for _, dep := range orch.dependencies(path) {
+			if recur(dep) {
+				return true
+			}
+		}
+		return false
+	}
+	return recur(from)
+}
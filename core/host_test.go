@@ -0,0 +1,76 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"testing"
+)
+
+// hostImpl returns an implementation that calls use with its Host from Start.
+func hostImpl(path core.ComponentPath, capabilities []core.Capability, use func(core.Host)) core.ComponentImpl {
+	return core.ComponentImpl{
+		Path:         path,
+		Capabilities: capabilities,
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			use(host)
+			return &fakeComponent{fake: comptest.NewFakeReference(), done: ctx.Done()}
+		},
+	}
+}
+
+func TestHostGrantsRequestedCapabilities(t *testing.T) {
+	var reader core.StatusReader
+	var path core.ComponentPath
+	orch := core.NewOrchestrator(hostImpl("watcher", []core.Capability{core.StatusCapability}, func(host core.Host) {
+		path = host.Path()
+		reader = host.Status()
+	}))
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	if path != "watcher" {
+		t.Errorf("host for %q", path)
+	}
+	if state := reader.Status()["watcher"].State; state != core.RunningState {
+		t.Errorf("status capability reports watcher %s", state)
+	}
+}
+
+func TestHostWithholdsOtherCapabilities(t *testing.T) {
+	orch := core.NewOrchestrator(hostImpl("sneaky", []core.Capability{core.StatusCapability}, func(host core.Host) {
+		host.Lifecycle().RequestShutdown(nil)
+	}))
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	var panicErr *core.PanicError
+	if _, err := orch.Root.Request(context.Background(), "ping"); !errors.As(err, &panicErr) {
+		t.Fatalf("request returned %v, want the *core.PanicError from Start", err)
+	}
+	eventually(t, "sneaky to fail", state(orch, "sneaky", core.FailedState))
+}
+
+func TestHostProvidesComponentState(t *testing.T) {
+	orch := core.NewOrchestrator(fakeImpl("svc", comptest.NewFakeReference(), nil, nil))
+	source := &configs{}
+	source.set(map[core.ComponentPath]core.Config{"svc": core.Config(`{"level": "debug"}`)}, nil)
+	orch.SetConfigSource(source.source)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	host := orch.Host("svc", nil)
+	if got := string(host.Config()); got != `{"level": "debug"}` {
+		t.Errorf("config %q", got)
+	}
+	if host.Clock() != orch.Clock() || host.Snapshot() != nil || host.Handoff() != nil {
+		t.Error("host does not reflect the orchestrator")
+	}
+}
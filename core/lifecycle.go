@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// lifecycleEventTimeout is the time allowed for the registered reference to
+// accept a LifecycleEvent before it is dropped.
+const lifecycleEventTimeout = 100 * time.Millisecond
+
+// LifecycleEventKind identifies the kind of a LifecycleEvent.  It is one of
+// the *Event constants.
+type LifecycleEventKind string
+
+// LifecycleEventKind values
+const (
+	// BreakerEvent indicates that a circuit breaker changed state.
+	BreakerEvent LifecycleEventKind = "breaker"
+
+	// AccessDeniedEvent indicates that a request was rejected by the target's
+	// AccessPolicy.
+	AccessDeniedEvent LifecycleEventKind = "access-denied"
+)
+
+// LifecycleEvent describes a change in the orchestrator's view of the
+// components.  The orchestrator sends these as messages to the component
+// registered with Orchestrator#HandleLifecycleEvents.
+type LifecycleEvent struct {
+	// Kind is the kind of event.
+	Kind LifecycleEventKind
+
+	// Component is the component concerned; for BreakerEvent and
+	// AccessDeniedEvent, the caller.
+	Component ComponentPath
+
+	// Dependency is, for BreakerEvent and AccessDeniedEvent, the target of the
+	// caller's reference.
+	Dependency ComponentPath
+
+	// State is the new state; for BreakerEvent, a BreakerState.  It is empty
+	// for events that do not change a state, such as AccessDeniedEvent.
+	State string
+
+	// Message is the type of the message concerned; for AccessDeniedEvent,
+	// the rejected message, e.g., `debug.Serve`.  It is empty for
+	// BreakerEvent.
+	Message string
+
+	// Time is the time at which the event occurred.
+	Time time.Time
+}
+
+// Topic returns the topic on which the event is published on
+// `core/comp/bus.Main`: `lifecycle.<kind>`.
+func (ev LifecycleEvent) Topic() string {
+	return "lifecycle." + string(ev.Kind)
+}
+
+func (ev LifecycleEvent) String() string {
+	detail := ev.State
+	if ev.Message != "" {
+		detail = ev.Message
+	}
+	if ev.Dependency != "" {
+		return fmt.Sprintf("%s %s -> %s: %s", ev.Kind, ev.Component, ev.Dependency, detail)
+	}
+	return fmt.Sprintf("%s %s: %s", ev.Kind, ev.Component, detail)
+}
+
+// HandleLifecycleEvents registers a reference to which LifecycleEvent
+// messages will be sent.  Only one such reference may be registered; this is
+// typically done by the `core/comp/bus.Main` component, which publishes them.
+// Until a reference is registered, events are discarded.
+//
+// Events are sent with RequestAsync, with a context that expires after
+// lifecycleEventTimeout and is cancelled when RequestAsync returns.  An event
+// that the reference cannot accept in that time, e.g., because its mailbox is
+// full, is dropped, so that a slow sink never holds up the requests and
+// breakers that produce events.
+func (orch *Orchestrator) HandleLifecycleEvents(ref ComponentReference) {
+	orch.eventsMu.Lock()
+	defer orch.eventsMu.Unlock()
+
+	orch.lifecycleEvents = ref
+}
+
+// lifecycleEvent delivers a LifecycleEvent to the registered reference, if
+// any.
+func (orch *Orchestrator) lifecycleEvent(ev LifecycleEvent) {
+	orch.eventsMu.Lock()
+	ref := orch.lifecycleEvents
+	orch.eventsMu.Unlock()
+
+	if ref == nil {
+		return
+	}
+	ev.Time = orch.clock.Now()
+	// this uses real time, since nothing may advance a fake clock while a
+	// request waits for the event to be accepted
+	ctx, cancel := context.WithTimeout(context.Background(), lifecycleEventTimeout)
+	defer cancel()
+	ref.RequestAsync(ctx, ev)
+}
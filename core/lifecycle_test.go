@@ -0,0 +1,69 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// eventSink records the lifecycle events sent to it.
+type eventSink struct {
+	core.BaseComponentReference
+	events chan core.LifecycleEvent
+}
+
+func (s *eventSink) RequestAsync(ctx context.Context, msg core.Message) {
+	s.events <- msg.(core.LifecycleEvent)
+}
+
+// stuckSink is a lifecycle event sink whose mailbox is full, and never
+// drained.
+type stuckSink struct {
+	core.BaseComponentReference
+	mailbox *core.Mailbox
+}
+
+func (s *stuckSink) RequestAsync(ctx context.Context, msg core.Message) {
+	if err := s.mailbox.Put(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+func TestLifecycleEventsDroppedBySlowSink(t *testing.T) {
+	var ref core.ComponentReference
+	svcImpl := fakeImpl("svc", comptest.NewFakeReference(), nil, nil)
+	svcImpl.Access = core.AccessPolicy{}
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				ref = deps["svc"]
+			}),
+		svcImpl,
+	)
+	sink := &stuckSink{mailbox: core.NewMailbox(core.MailboxConfig{Capacity: 1, Overflow: core.BlockOverflow})}
+	sink.mailbox.Put(context.Background(), "full")
+	orch.HandleLifecycleEvents(sink)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	// each denied request produces an event, which the sink cannot accept
+	done := make(chan error, 1)
+	go func() {
+		_, err := ref.Request(context.Background(), "denied")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		var denied *core.AccessDeniedError
+		if !errors.As(err, &denied) {
+			t.Fatalf("got %v, want an *AccessDeniedError", err)
+		}
+	case <-time.After(comptest.DefaultTimeout):
+		t.Fatal("request blocked on the lifecycle event sink")
+	}
+}
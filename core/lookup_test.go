@@ -0,0 +1,78 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// startLookup starts root, which depends on a and b, where a has the lookup
+// capability.  It returns the orchestrator, a's Lookup, and b's fake.
+func startLookup(t *testing.T) (*core.Orchestrator, core.Lookup, *comptest.FakeReference) {
+	t.Helper()
+
+	var lookup core.Lookup
+	a := hostImpl("a", []core.Capability{core.LookupCapability}, func(host core.Host) {
+		lookup = host.Lookup()
+	})
+	b := comptest.NewFakeReference()
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"a", "b"}, nil),
+		a,
+		fakeImpl("b", b, nil, nil),
+		fakeImpl("idle", comptest.NewFakeReference(), nil, nil),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+	return orch, lookup, b
+}
+
+func TestLookupRecordsDynamicDependency(t *testing.T) {
+	orch, lookup, b := startLookup(t)
+
+	ref, err := lookup.Lookup("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.Request(context.Background(), "ping")
+	if caller, _ := core.Caller(b.Received()[0].Ctx); caller != "a" {
+		t.Errorf("request through looked-up reference from %q, want a", caller)
+	}
+
+	status := orch.Status()
+	if deps := status["a"].Dependencies; !reflect.DeepEqual(deps, []core.ComponentPath{"b"}) {
+		t.Errorf("a depends on %v, want [b]", deps)
+	}
+	if dependents := status["b"].Dependents; !reflect.DeepEqual(dependents, []core.ComponentPath{"a", "root"}) {
+		t.Errorf("b depended on by %v, want [a root]", dependents)
+	}
+	found := false
+	for _, edge := range orch.Graph().Edges {
+		found = found || edge == core.GraphEdge{From: "a", To: "b", Dynamic: true}
+	}
+	if !found {
+		t.Errorf("no dynamic edge in %v", orch.Graph().Edges)
+	}
+	if dot := orch.GraphDOT(); !strings.Contains(dot, `"a" -> "b" [style=dashed];`) {
+		t.Errorf("dynamic edge not dashed in\n%s", dot)
+	}
+}
+
+func TestLookupFailures(t *testing.T) {
+	_, lookup, _ := startLookup(t)
+
+	for path, want := range map[core.ComponentPath]string{
+		"missing": "no component with that path",
+		"idle":    "component is not running",
+		"root":    "dependency cycle",
+	} {
+		if _, err := lookup.Lookup(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Lookup(%s) returned %v, want %q", path, err, want)
+		}
+	}
+}
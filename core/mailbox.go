@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy determines what a Mailbox does when a message arrives and the
+// mailbox is full.  It is one of the *Overflow constants.
+type OverflowPolicy string
+
+// OverflowPolicy values
+const (
+	// BlockOverflow blocks the sender until there is room in the mailbox, or
+	// the sender's context is cancelled.
+	BlockOverflow OverflowPolicy = "block"
+
+	// DropNewestOverflow discards the incoming message, leaving the mailbox
+	// unchanged.
+	DropNewestOverflow OverflowPolicy = "drop-newest"
+
+	// DropOldestOverflow discards the oldest message in the mailbox to make
+	// room for the incoming message.
+	DropOldestOverflow OverflowPolicy = "drop-oldest"
+
+	// RejectOverflow discards the incoming message and returns ErrMailboxFull
+	// to the sender.
+	RejectOverflow OverflowPolicy = "reject"
+)
+
+// mailboxPollInterval is the interval at which waitEmpty checks the mailbox
+const mailboxPollInterval = 5 * time.Millisecond
+
+// ErrMailboxFull is returned from Mailbox#Put when the mailbox is full and
+// its policy is RejectOverflow.
+var ErrMailboxFull = errors.New("Mailbox is full")
+
+// MailboxConfig configures a Mailbox.
+type MailboxConfig struct {
+	// Capacity is the number of messages the mailbox can hold.  A capacity of
+	// zero is treated as one.
+	Capacity int
+
+	// Overflow is the policy applied when the mailbox is full.  The default
+	// (empty) value is BlockOverflow.
+	Overflow OverflowPolicy
+}
+
+// Envelope is a message in a Mailbox, along with the context with which it
+// was sent.
+type Envelope struct {
+	Ctx context.Context
+	Msg Message
+}
+
+// Mailbox is a bounded queue of incoming messages for a component, with a
+// configurable overflow policy.  A component typically puts messages into the
+// mailbox in RequestAsync, and receives them from C() in its own goroutine,
+// handling each with HandleEnvelope.
+type Mailbox struct {
+	// dropped and rejected are accessed atomically, so they come first to
+	// ensure 64-bit alignment
+	dropped  uint64
+	rejected uint64
+
+	config MailboxConfig
+	ch     chan Envelope
+}
+
+// MailboxStats describes the current state of a Mailbox.
+type MailboxStats struct {
+	// Capacity is the configured capacity of the mailbox.
+	Capacity int
+
+	// Overflow is the configured overflow policy of the mailbox.
+	Overflow OverflowPolicy
+
+	// Depth is the number of messages currently waiting in the mailbox.
+	Depth int
+
+	// Dropped is the number of messages discarded by DropNewestOverflow or
+	// DropOldestOverflow.
+	Dropped uint64
+
+	// Rejected is the number of messages refused by RejectOverflow.
+	Rejected uint64
+}
+
+// MailboxComponent is implemented by components that receive messages
+// through a Mailbox.  The orchestrator includes the mailbox's stats in the
+// component's ComponentStatus.
+type MailboxComponent interface {
+	Mailbox() *Mailbox
+}
+
+// NewMailbox creates a new Mailbox with the given configuration.
+func NewMailbox(config MailboxConfig) *Mailbox {
+	if config.Capacity < 1 {
+		config.Capacity = 1
+	}
+	if config.Overflow == "" {
+		config.Overflow = BlockOverflow
+	}
+	return &Mailbox{
+		config: config,
+		ch:     make(chan Envelope, config.Capacity),
+	}
+}
+
+// Put adds a message to the mailbox, applying the overflow policy if the
+// mailbox is full.  It returns an error if the message was not added, either
+// because the mailbox rejected it or because the context was cancelled while
+// blocked.  Messages dropped by a Drop* policy do not cause an error, but are
+// reported with ReportAsyncFailure using the context with which each was sent.
+func (mb *Mailbox) Put(ctx context.Context, msg Message) error {
+	env := Envelope{Ctx: ctx, Msg: msg}
+
+	switch mb.config.Overflow {
+	case DropNewestOverflow:
+		select {
+		case mb.ch <- env:
+		default:
+			atomic.AddUint64(&mb.dropped, 1)
+			ReportAsyncFailure(ctx, ErrMessageDropped)
+		}
+		return nil
+
+	case DropOldestOverflow:
+		for {
+			select {
+			case mb.ch <- env:
+				return nil
+			default:
+			}
+			select {
+			case old := <-mb.ch:
+				atomic.AddUint64(&mb.dropped, 1)
+				ReportAsyncFailure(old.Ctx, ErrMessageDropped)
+			default:
+			}
+		}
+
+	case RejectOverflow:
+		select {
+		case mb.ch <- env:
+			return nil
+		default:
+			atomic.AddUint64(&mb.rejected, 1)
+			return ErrMailboxFull
+		}
+
+	default:
+		select {
+		case mb.ch <- env:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// C returns the channel from which the owning component receives messages.
+func (mb *Mailbox) C() <-chan Envelope {
+	return mb.ch
+}
+
+// Stats returns the current stats for this mailbox.
+func (mb *Mailbox) Stats() MailboxStats {
+	return MailboxStats{
+		Capacity: mb.config.Capacity,
+		Overflow: mb.config.Overflow,
+		Depth:    len(mb.ch),
+		Dropped:  atomic.LoadUint64(&mb.dropped),
+		Rejected: atomic.LoadUint64(&mb.rejected),
+	}
+}
+
+// waitEmpty waits until the mailbox is empty, or the context expires.  The
+// mailbox cannot observe its owner receiving messages, so this polls.  It uses
+// real time, since the orchestrator's clock may be a fake that nothing
+// advances while a component is replaced.
+func (mb *Mailbox) waitEmpty(ctx context.Context) error {
+	ticker := time.NewTicker(mailboxPollInterval)
+	defer ticker.Stop()
+	for len(mb.ch) > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
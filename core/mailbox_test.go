@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// droppedInto returns a context whose async failures are appended to errs.
+func droppedInto(errs *[]error) context.Context {
+	return withAsyncFailureHandler(context.Background(), func(err error) {
+		*errs = append(*errs, err)
+	})
+}
+
+// contents takes every message waiting in the mailbox.
+func contents(mb *Mailbox) []Message {
+	msgs := []Message{}
+	for {
+		select {
+		case env := <-mb.C():
+			msgs = append(msgs, env.Msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestMailboxBlockOverflowWaitsForContext(t *testing.T) {
+	mb := NewMailbox(MailboxConfig{Capacity: 1})
+	if err := mb.Put(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mb.Put(ctx, 2); err != context.DeadlineExceeded {
+		t.Fatalf("Put into a full mailbox returned %v, want context.DeadlineExceeded", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- mb.Put(context.Background(), 3) }()
+	<-mb.C()
+	if err := <-done; err != nil {
+		t.Fatalf("blocked Put returned %v once there was room", err)
+	}
+	if got := contents(mb); !reflect.DeepEqual(got, []Message{3}) {
+		t.Fatalf("mailbox holds %v, want [3]", got)
+	}
+}
+
+func TestMailboxDropNewestOverflow(t *testing.T) {
+	mb := NewMailbox(MailboxConfig{Capacity: 2, Overflow: DropNewestOverflow})
+	errs := []error{}
+	for i := 1; i <= 3; i++ {
+		if err := mb.Put(droppedInto(&errs), i); err != nil {
+			t.Fatalf("Put %d: %s", i, err)
+		}
+	}
+
+	if stats := mb.Stats(); stats.Dropped != 1 || stats.Depth != 2 {
+		t.Fatalf("stats %+v, want 1 dropped and depth 2", stats)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrMessageDropped) {
+		t.Fatalf("reported %v, want one ErrMessageDropped", errs)
+	}
+	if got := contents(mb); !reflect.DeepEqual(got, []Message{1, 2}) {
+		t.Fatalf("mailbox holds %v, want [1 2]", got)
+	}
+}
+
+func TestMailboxDropOldestOverflow(t *testing.T) {
+	mb := NewMailbox(MailboxConfig{Capacity: 2, Overflow: DropOldestOverflow})
+	errs := []error{}
+	for i := 1; i <= 4; i++ {
+		if err := mb.Put(droppedInto(&errs), i); err != nil {
+			t.Fatalf("Put %d: %s", i, err)
+		}
+	}
+
+	if stats := mb.Stats(); stats.Dropped != 2 {
+		t.Fatalf("stats %+v, want 2 dropped", stats)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("reported %v, want two ErrMessageDropped", errs)
+	}
+	if got := contents(mb); !reflect.DeepEqual(got, []Message{3, 4}) {
+		t.Fatalf("mailbox holds %v, want [3 4]", got)
+	}
+}
+
+func TestMailboxRejectOverflow(t *testing.T) {
+	mb := NewMailbox(MailboxConfig{Capacity: 1, Overflow: RejectOverflow})
+	if err := mb.Put(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mb.Put(context.Background(), 2); err != ErrMailboxFull {
+		t.Fatalf("Put into a full mailbox returned %v, want ErrMailboxFull", err)
+	}
+	if stats := mb.Stats(); stats.Rejected != 1 || stats.Dropped != 0 {
+		t.Fatalf("stats %+v, want 1 rejected", stats)
+	}
+}
+
+func TestMailboxDefaults(t *testing.T) {
+	stats := NewMailbox(MailboxConfig{}).Stats()
+	if stats.Capacity != 1 || stats.Overflow != BlockOverflow {
+		t.Fatalf("default stats %+v, want capacity 1 and block overflow", stats)
+	}
+}
+
+func TestMailboxWaitEmpty(t *testing.T) {
+	mb := NewMailbox(MailboxConfig{Capacity: 1})
+	if err := mb.waitEmpty(context.Background()); err != nil {
+		t.Fatalf("waitEmpty on an empty mailbox returned %v", err)
+	}
+
+	if err := mb.Put(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- mb.waitEmpty(context.Background()) }()
+	<-mb.C()
+	if err := <-done; err != nil {
+		t.Fatalf("waitEmpty returned %v", err)
+	}
+
+	if err := mb.Put(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mb.waitEmpty(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("waitEmpty on a full mailbox returned %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestHandleEnvelopeRecoversPanics(t *testing.T) {
+	errs := []error{}
+	HandleEnvelope(Envelope{Ctx: droppedInto(&errs), Msg: "panic"}, func(context.Context, Message) (Message, error) {
+		panic("boom")
+	})
+
+	var panicErr *PanicError
+	if len(errs) != 1 || !errors.As(errs[0], &panicErr) || panicErr.Value != "boom" {
+		t.Fatalf("reported %v, want one *PanicError", errs)
+	}
+}
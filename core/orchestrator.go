@@ -1,10 +1,15 @@
 package core
 
 import (
+	"comps/core/clock"
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
+	"runtime/debug"
+	"sort"
 	"sync"
+	"time"
 )
 
 // Orchestrator orchestrates multiple components.
@@ -17,17 +22,110 @@ type Orchestrator struct {
 	// active contains all active components (those in the dependency graph of Root)
 	active map[ComponentPath]activeComponent
 
+	// bindings contains the binding for each active component to which
+	// references have been handed out
+	bindings map[ComponentPath]*binding
+
+	// dynamic contains the dependencies each active component has acquired
+	// with Lookup, keyed by dependent and then by dependency
+	dynamic map[ComponentPath]map[ComponentPath]bool
+
+	// providers contains the providers selected with SetProvider
+	providers map[Service]ComponentPath
+
+	// overrides contains the original Path of each implementation given to
+	// Override, keyed by the path it overrides; a path is overridden if it
+	// has an entry, even an empty one
+	overrides map[ComponentPath]ComponentPath
+
 	// Root is a ComponentReference to the root component (set after Start)
 	Root ComponentReference
 
 	// RootPath is the path of the root component (the first argument to NewOrchestrator)
 	RootPath ComponentPath
+
+	// clock is the clock handed to components
+	clock clock.Clock
+
+	// stopTimeout is the time Run allows for components to stop
+	stopTimeout time.Duration
+
+	// shutdown carries the first shutdown request (see RequestShutdown)
+	shutdown chan error
+
+	// lifecycleMu serializes operations that start and stop components
+	// (Start, Stop, and Reconfigure).  It is always acquired before mu.
+	lifecycleMu sync.Mutex
+
+	// configMu protects configSource and config.  This is separate from mu
+	// because components read their configuration while mu is held, during
+	// Start.
+	configMu     sync.Mutex
+	configSource ConfigSource
+	config       map[ComponentPath]Config
+
+	// snapshotMu protects snapshotStore, snapshots, and handoff.  This is
+	// separate from mu because components read their snapshots while mu is
+	// held, during Start.
+	snapshotMu    sync.Mutex
+	snapshotStore SnapshotStore
+	snapshots     map[ComponentPath][]byte
+	handoff       map[ComponentPath]interface{}
+
+	// historyMu protects history.  This is separate from mu because errors
+	// may be recorded while mu is held, e.g., during Start.
+	historyMu sync.Mutex
+	history   map[ComponentPath]*componentHistory
+
+	// deadLetterMu protects deadLetters.  This is separate from mu because
+	// dead letters may be reported while mu is held, e.g., during Start.
+	deadLetterMu sync.Mutex
+
+	// deadLetters is the reference to which DeadLetter messages are sent, or nil
+	deadLetters ComponentReference
+
+	// eventsMu protects lifecycleEvents.  Like deadLetterMu, this is separate
+	// from mu because events may be produced while mu is held.
+	eventsMu sync.Mutex
+
+	// lifecycleEvents is the reference to which LifecycleEvent messages are
+	// sent, or nil
+	lifecycleEvents ComponentReference
 }
 
 type activeComponent struct {
 	stop  context.CancelFunc
 	comp  Component
 	state ComponentState
+
+	// started is when the component was started, and startDuration the time
+	// its Start function took
+	started       time.Time
+	startDuration time.Duration
+
+	// generation distinguishes instances of the same component; it counts
+	// the starts of the component
+	generation int
+
+	// breakers contains the circuit breakers on the component's references
+	// to its dependencies, keyed by dependency
+	breakers map[ComponentPath]*breaker
+}
+
+// requestTimeouts counts requests that failed because the default timeout for
+// their edge expired, keyed by target and then by caller.  This is published
+// with expvar (see `core/comp/debug.Expvar`).
+var requestTimeouts = expvar.NewMap("comps.requestTimeouts")
+
+// componentHistory records information about a component that outlives its
+// individual instances.
+type componentHistory struct {
+	starts        int
+	failures      int
+	timeouts      int
+	denied        int
+	lastError     error
+	lastErrorTime time.Time
 }
 
 // NewOrchestrator creates a new orchestrator, containing the given component
@@ -36,8 +134,18 @@ type activeComponent struct {
 // instantiated.
 func NewOrchestrator(componentImpls ...ComponentImpl) *Orchestrator {
 	orch := &Orchestrator{
-		registered: make(map[ComponentPath]ComponentImpl),
-		active:     make(map[ComponentPath]activeComponent),
+		registered:  make(map[ComponentPath]ComponentImpl),
+		active:      make(map[ComponentPath]activeComponent),
+		bindings:    make(map[ComponentPath]*binding),
+		dynamic:     make(map[ComponentPath]map[ComponentPath]bool),
+		providers:   make(map[Service]ComponentPath),
+		overrides:   make(map[ComponentPath]ComponentPath),
+		clock:       clock.Real(),
+		stopTimeout: DefaultStopTimeout,
+		shutdown:    make(chan error, 1),
+		snapshots:   make(map[ComponentPath][]byte),
+		handoff:     make(map[ComponentPath]interface{}),
+		history:     make(map[ComponentPath]*componentHistory),
 	}
 	for _, ci := range componentImpls {
 		orch.registered[ci.Path] = ci
@@ -46,11 +154,29 @@ func NewOrchestrator(componentImpls ...ComponentImpl) *Orchestrator {
 	return orch
 }
 
+// SetClock sets the clock that the orchestrator uses, and hands to components.
+// This must be called before Start.  By default, the orchestrator uses
+// clock.Real().
+func (orch *Orchestrator) SetClock(clk clock.Clock) {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	orch.clock = clk
+}
+
+// Clock returns the orchestrator's clock.  Components should use this clock,
+// rather than the time package, for anything timing-dependent.
+func (orch *Orchestrator) Clock() clock.Clock {
+	return orch.clock
+}
+
 // Start starts an orchestrator by starting the component with the named path
 // (and all of its dependencies) and returning a ComponentReference.  Typically
 // the next step is to call `compRef.Request(componentpkg.StartMessage{..})` to
 // pass information to the component and cause it to start.
 func (orch *Orchestrator) Start() error {
+	orch.lifecycleMu.Lock()
+	defer orch.lifecycleMu.Unlock()
 	orch.mu.Lock()
 	defer orch.mu.Unlock()
 
@@ -58,6 +184,13 @@ func (orch *Orchestrator) Start() error {
 		return errors.New("Orchestrator has already been started")
 	}
 
+	if _, err := orch.loadConfig(); err != nil {
+		return err
+	}
+	if err := orch.loadSnapshots(); err != nil {
+		return err
+	}
+
 	root, err := orch.getComponentReference(orch.RootPath)
 	orch.Root = root
 	return err
@@ -65,58 +198,244 @@ func (orch *Orchestrator) Start() error {
 
 // Stop stops a running orchestrator, in an orderly fashion.  Components are
 // stopped only after everything depending on them are stopped.  This method will
-// block until all components are stopped, or the passed context expires.
+// block until all components are stopped, or the passed context expires.  Use
+// clock.WithTimeout with the orchestrator's clock to set a deadline that
+// honors that clock.
+//
+// Components implementing Snapshotter are snapshotted as they are stopped.  A
+// failure to take or save a snapshot does not prevent the remaining components
+// from stopping, but is included in the returned error.
 func (orch *Orchestrator) Stop(stopCtx context.Context) error {
+	orch.lifecycleMu.Lock()
+	defer orch.lifecycleMu.Unlock()
+
 	// components are stopped in the reverse of the order in which they were started
-	order := make([]ComponentPath, len(orch.active))
-	i := len(orch.active) - 1
+	errs := []error{}
+	order := orch.startOrder()
+	for i := len(order) - 1; i >= 0; i-- {
+		if err := orch.stopComponent(stopCtx, order[i]); err != nil {
+			if stopCtx.Err() != nil {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startOrder returns the paths of the active components in the order in which
+// they are started, with each component after all of its dependencies.
+// Components not reachable from the root, such as those started before a
+// failed Start, come last.
+func (orch *Orchestrator) startOrder() []ComponentPath {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	order := []ComponentPath{}
 	seen := map[ComponentPath]struct{}{}
 	var recur func(path ComponentPath)
 	recur = func(path ComponentPath) {
 		_, found := seen[path]
-		if !found {
+		_, active := orch.active[path]
+		if !found && active {
 			seen[path] = struct{}{}
-			for _, dep := range orch.registered[path].Dependencies {
+			for _, dep := range orch.dependencies(path) {
 				recur(dep)
 			}
-			order[i] = path
-			i--
+			order = append(order, path)
 		}
 	}
 	recur(orch.RootPath)
-	if i != -1 {
-		panic("not zero")
+	rest := []ComponentPath{}
+	for path := range orch.active {
+		if _, found := seen[path]; !found {
+			rest = append(rest, path)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i] < rest[j] })
+	for _, path := range rest {
+		recur(path)
 	}
+	return order
+}
 
-	for _, path := range order {
-		acomp := orch.active[path]
-		acomp.stop()
-		select {
-		case <-acomp.comp.Done():
-		case <-stopCtx.Done():
-			return stopCtx.Err()
-		}
+// stopComponent snapshots a single component, then stops it and waits until it
+// is done, or the context expires.  References to the component report that
+// it is done (see reference#Done) as soon as it begins stopping.  A snapshot
+// error is returned only once the component has stopped.
+func (orch *Orchestrator) stopComponent(ctx context.Context, path ComponentPath) error {
+	acomp := orch.setState(path, StoppingState)
+	orch.mu.Lock()
+	if b, found := orch.bindings[path]; found {
+		b.retire()
+	}
+	orch.mu.Unlock()
+	snapshotErr := orch.snapshot(path, acomp.comp)
+	acomp.stop()
+	select {
+	case <-acomp.comp.Done():
+		orch.setState(path, StoppedState)
+		return snapshotErr
+	case <-ctx.Done():
+		return fmt.Errorf("Stopping %s: %w", path, ctx.Err())
 	}
-	return nil
 }
 
 // Status returns the status of the orchestrator, in the form of a map from
 // component path to information about that component.
 func (orch *Orchestrator) Status() map[ComponentPath]ComponentStatus {
-	orch.mu.Lock()
-	defer orch.mu.Unlock()
-
+	now := orch.clock.Now()
 	rv := map[ComponentPath]ComponentStatus{}
+	comps := map[ComponentPath]Component{}
+
+	orch.mu.Lock()
 	for path, acomp := range orch.active {
-		deps := orch.registered[path].Dependencies
-		rv[path] = ComponentStatus{
-			Dependencies: deps,
-			State:        acomp.state,
+		deps := orch.dependencies(path)
+		status := ComponentStatus{
+			Dependencies:  deps,
+			Dependents:    []ComponentPath{},
+			State:         acomp.state,
+			StartTime:     acomp.started,
+			Uptime:        now.Sub(acomp.started),
+			StartDuration: acomp.startDuration,
 		}
+		status.OverriddenBy, status.Overridden = orch.overrides[path]
+		for p := range orch.active {
+			for _, dep := range orch.dependencies(p) {
+				if dep == path {
+					status.Dependents = append(status.Dependents, p)
+				}
+			}
+		}
+		sort.Slice(status.Dependents, func(i, j int) bool {
+			return status.Dependents[i] < status.Dependents[j]
+		})
+		if len(acomp.breakers) > 0 {
+			status.Breakers = map[ComponentPath]BreakerState{}
+			for dep, b := range acomp.breakers {
+				status.Breakers[dep] = b.State()
+			}
+		}
+		rv[path] = status
+		comps[path] = acomp.comp
+	}
+	orch.mu.Unlock()
+
+	orch.historyMu.Lock()
+	for path, status := range rv {
+		if h, found := orch.history[path]; found {
+			status.Restarts = h.starts - 1
+			status.Failures = h.failures
+			status.Timeouts = h.timeouts
+			status.Denied = h.denied
+			status.LastError = h.lastError
+			status.LastErrorTime = h.lastErrorTime
+		}
+		rv[path] = status
+	}
+	orch.historyMu.Unlock()
+
+	// components' own stats are gathered without holding any locks, in case
+	// they are slow
+	for path, comp := range comps {
+		status := rv[path]
+		if mc, ok := comp.(MailboxComponent); ok {
+			stats := mc.Mailbox().Stats()
+			status.Mailbox = &stats
+		}
+		if sc, ok := comp.(StatsComponent); ok {
+			status.Stats = sc.Stats()
+		}
+		rv[path] = status
 	}
 	return rv
 }
 
+// recordStart records that a new instance of a component has started,
+// returning the instance's generation.
+func (orch *Orchestrator) recordStart(path ComponentPath) int {
+	orch.historyMu.Lock()
+	defer orch.historyMu.Unlock()
+
+	h := orch.historyFor(path)
+	h.starts++
+	return h.starts
+}
+
+// recordError records an error from a request to a component.
+func (orch *Orchestrator) recordError(path ComponentPath, err error) {
+	orch.historyMu.Lock()
+	defer orch.historyMu.Unlock()
+
+	h := orch.historyFor(path)
+	h.lastError = err
+	h.lastErrorTime = orch.clock.Now()
+}
+
+// recordTimeout records a request that failed because the default timeout
+// for its edge expired.
+func (orch *Orchestrator) recordTimeout(caller, target ComponentPath) {
+	orch.historyMu.Lock()
+	defer orch.historyMu.Unlock()
+
+	orch.historyFor(target).timeouts++
+
+	callers, ok := requestTimeouts.Get(string(target)).(*expvar.Map)
+	if !ok {
+		callers = new(expvar.Map)
+		requestTimeouts.Set(string(target), callers)
+	}
+	callers.Add(string(caller), 1)
+}
+
+// historyFor returns the history for a component, creating it if necessary.
+// This assumes that orch.historyMu is held.
+func (orch *Orchestrator) historyFor(path ComponentPath) *componentHistory {
+	h, found := orch.history[path]
+	if !found {
+		h = &componentHistory{}
+		orch.history[path] = h
+	}
+	return h
+}
+
+// setState sets the state of an active component, returning that component.
+func (orch *Orchestrator) setState(path ComponentPath, state ComponentState) activeComponent {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	acomp := orch.active[path]
+	acomp.state = state
+	orch.active[path] = acomp
+	return acomp
+}
+
+// HandleDeadLetters registers a reference to which every failed asynchronous
+// request will be sent, as a DeadLetter message.  Only one such reference may
+// be registered; this is typically done by the `core/comp/deadletter.Main`
+// component.  Until a reference is registered, dead letters are discarded.
+func (orch *Orchestrator) HandleDeadLetters(ref ComponentReference) {
+	orch.deadLetterMu.Lock()
+	defer orch.deadLetterMu.Unlock()
+
+	orch.deadLetters = ref
+}
+
+// deadLetter delivers a DeadLetter to the registered reference, if any.
+func (orch *Orchestrator) deadLetter(dl DeadLetter) {
+	orch.deadLetterMu.Lock()
+	ref := orch.deadLetters
+	orch.deadLetterMu.Unlock()
+
+	if ref == nil {
+		return
+	}
+	dl.Time = orch.clock.Now()
+	// note that this context has no failure handler, so failures to handle
+	// dead letters cannot recurse
+	ref.RequestAsync(context.Background(), dl)
+}
+
 // getComponentReference loads the given component, if it is not already loaded, and returns
 // a reference to it.  This assumes that orch.mu is held.
 func (orch *Orchestrator) getComponentReference(path ComponentPath) (ComponentReference, error) {
@@ -134,20 +453,75 @@ func (orch *Orchestrator) getComponentReference(path ComponentPath) (ComponentRe
 
 			seen = append(seen, path)
 
+			// a new instance has yet to look anything up
+			delete(orch.dynamic, path)
+
+			breakers := map[ComponentPath]*breaker{}
+			newReference := func(depPath ComponentPath) (ComponentReference, error) {
+				if _, err := recur(seen, depPath); err != nil {
+					return nil, err
+				}
+				ref := &reference{
+					orch:    orch,
+					caller:  path,
+					target:  depPath,
+					binding: orch.binding(depPath),
+					timeout: compImpl.Timeouts[depPath],
+					retry:   compImpl.Retries[depPath],
+				}
+				if policy, found := compImpl.Breakers[depPath]; found {
+					ref.breaker = newBreaker(orch, path, depPath, policy)
+					breakers[depPath] = ref.breaker
+				}
+				return ref, nil
+			}
+
 			deps := map[ComponentPath]ComponentReference{}
 			for _, depPath := range compImpl.Dependencies {
-				ref, err := recur(seen, depPath)
+				ref, err := newReference(depPath)
 				if err != nil {
 					return nil, err
 				}
 				deps[depPath] = ref
 			}
 
+			providers, err := orch.serviceProviders(path)
+			if err != nil {
+				return nil, err
+			}
+			services := map[Service][]ComponentReference{}
+			for service, paths := range providers {
+				services[service] = []ComponentReference{}
+				for _, depPath := range paths {
+					ref, err := newReference(depPath)
+					if err != nil {
+						return nil, err
+					}
+					services[service] = append(services[service], ref)
+				}
+			}
+
 			ctx, stop := context.WithCancel(bkgnd)
+			generation := orch.recordStart(path)
+			started := orch.clock.Now()
+			var comp Component
+			withComponentLabel(ctx, path, "", func(ctx context.Context) {
+				defer func() {
+					if value := recover(); value != nil {
+						err := orch.panicked(path, generation, value, string(debug.Stack()))
+						comp = newFailedComponent(err)
+					}
+				}()
+				comp = compImpl.Start(orch.host(path, compImpl.Capabilities, services), ctx, deps)
+			})
 			acomp = activeComponent{
-				comp:  compImpl.Start(orch, ctx, deps),
-				stop:  stop,
-				state: RunningState,
+				comp:          comp,
+				stop:          stop,
+				state:         RunningState,
+				started:       started,
+				startDuration: orch.clock.Now().Sub(started),
+				generation:    generation,
+				breakers:      breakers,
 			}
 			orch.active[path] = acomp
 		}
@@ -157,3 +531,74 @@ func (orch *Orchestrator) getComponentReference(path ComponentPath) (ComponentRe
 
 	return recur([]ComponentPath{}, path)
 }
+
+// dependencies returns the dependencies of an active component: those in its
+// Dependencies, followed by the providers of the services it requires and
+// those it has acquired with Lookup.  This assumes that orch.mu is held.
+func (orch *Orchestrator) dependencies(path ComponentPath) []ComponentPath {
+	deps := append([]ComponentPath{}, orch.registered[path].Dependencies...)
+	for _, edge := range orch.implicitDependencies(path) {
+		deps = append(deps, edge.To)
+	}
+	return deps
+}
+
+// implicitDependencies returns the dependencies of an active component that
+// are not listed in its Dependencies, as graph edges: first the providers of
+// the services it requires, in order of service and then path, and then
+// those it has acquired with Lookup, in order.  Each dependency appears only
+// once.  This assumes that orch.mu is held.
+func (orch *Orchestrator) implicitDependencies(path ComponentPath) []GraphEdge {
+	seen := map[ComponentPath]bool{}
+	for _, dep := range orch.registered[path].Dependencies {
+		seen[dep] = true
+	}
+	edges := []GraphEdge{}
+
+	// the component is active, so its services were resolved when it started
+	providers, _ := orch.serviceProviders(path)
+	services := []Service{}
+	for service := range providers {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i] < services[j] })
+	for _, service := range services {
+		for _, dep := range providers[service] {
+			if !seen[dep] {
+				seen[dep] = true
+				edges = append(edges, GraphEdge{From: path, To: dep, Service: service})
+			}
+		}
+	}
+
+	dynamic := []ComponentPath{}
+	for dep := range orch.dynamic[path] {
+		if !seen[dep] {
+			dynamic = append(dynamic, dep)
+		}
+	}
+	sort.Slice(dynamic, func(i, j int) bool { return dynamic[i] < dynamic[j] })
+	for _, dep := range dynamic {
+		edges = append(edges, GraphEdge{From: path, To: dep, Dynamic: true})
+	}
+	return edges
+}
+
+// binding returns the binding for the given active component, creating it if
+// necessary.  This assumes that orch.mu is held.
+func (orch *Orchestrator) binding(path ComponentPath) *binding {
+	b, found := orch.bindings[path]
+	if !found {
+		acomp := orch.active[path]
+		b = &binding{
+			inst: &instance{
+				comp:       acomp.comp,
+				generation: acomp.generation,
+				access:     orch.registered[path].Access,
+			},
+			done: make(chan struct{}),
+		}
+		orch.bindings[path] = b
+	}
+	return b
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Override replaces the registered implementation of the component with the
+// given path, so that impl is started in its place, wherever it appears in
+// the graph.  This is intended for integration tests and staging, e.g., to
+// substitute an in-memory listener for a network one.  The override takes
+// the given path, whatever impl's own Path is, and is recorded in the
+// component's status.  This must be called before Start.
+func (orch *Orchestrator) Override(path ComponentPath, impl ComponentImpl) error {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	if orch.Root != nil {
+		return errors.New("Orchestrator has already been started")
+	}
+	if _, found := orch.registered[path]; !found {
+		return fmt.Errorf("No component with path %s to override", path)
+	}
+
+	orch.overrides[path] = impl.Path
+	impl.Path = path
+	orch.registered[path] = impl
+	return nil
+}
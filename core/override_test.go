@@ -0,0 +1,76 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"testing"
+)
+
+func TestOverride(t *testing.T) {
+	var ref core.ComponentReference
+	mock := comptest.NewFakeReference().Respond("mocked", nil)
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				ref = deps["svc"]
+			}),
+		fakeImpl("svc", comptest.NewFakeReference(), nil, nil),
+	)
+	if err := orch.Override("svc", fakeImpl("mock", mock, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	if rsp, _ := ref.Request(context.Background(), "ping"); rsp != "mocked" {
+		t.Errorf("got %v from the overridden component, want mocked", rsp)
+	}
+	status := orch.Status()
+	if !status["svc"].Overridden || status["svc"].OverriddenBy != "mock" || status["root"].Overridden {
+		t.Errorf("svc overridden by %q, root by %q", status["svc"].OverriddenBy, status["root"].OverriddenBy)
+	}
+	if _, found := status["mock"]; found {
+		t.Error("override started under its own path")
+	}
+}
+
+func TestOverrideErrors(t *testing.T) {
+	orch := core.NewOrchestrator(fakeImpl("svc", comptest.NewFakeReference(), nil, nil))
+	if err := orch.Override("missing", fakeImpl("mock", comptest.NewFakeReference(), nil, nil)); err == nil {
+		t.Error("Override of an unregistered component succeeded")
+	}
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+	if err := orch.Override("svc", fakeImpl("mock", comptest.NewFakeReference(), nil, nil)); err == nil {
+		t.Error("Override after Start succeeded")
+	}
+}
+
+func TestOverrideIsRecorded(t *testing.T) {
+	for _, by := range []core.ComponentPath{"", "svc", "mock"} {
+		orch := core.NewOrchestrator(
+			fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"}, nil),
+			fakeImpl("svc", comptest.NewFakeReference(), nil, nil),
+		)
+		if err := orch.Override("svc", fakeImpl(by, comptest.NewFakeReference(), nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if err := orch.Start(); err != nil {
+			t.Fatal(err)
+		}
+		status := orch.Status()
+		if !status["svc"].Overridden || status["svc"].OverriddenBy != by {
+			t.Errorf("override by %q: status has Overridden %v, OverriddenBy %q",
+				by, status["svc"].Overridden, status["svc"].OverriddenBy)
+		}
+		if status["root"].Overridden {
+			t.Errorf("override by %q: root is marked overridden", by)
+		}
+		stop(t, orch)
+	}
+}
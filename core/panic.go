@@ -0,0 +1,191 @@
+package core
+
+import (
+	"comps/core/clock"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError is the error returned to a caller when a component panics while
+// handling its request, and the error with which a component fails if its
+// Start function panics.
+type PanicError struct {
+	// Component is the path of the component that panicked, if known.
+	Component ComponentPath
+
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack of the goroutine that panicked.
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	if e.Component == "" {
+		return fmt.Sprintf("Panic: %v", e.Value)
+	}
+	return fmt.Sprintf("Panic in %s: %v", e.Component, e.Value)
+}
+
+// RestartPolicy determines how the orchestrator responds when a component
+// fails, by panicking in its Start function or while handling a request.  The
+// component is first marked as FailedState.  With the zero policy, it is left
+// that way; it is still running, and may or may not continue to work.
+type RestartPolicy struct {
+	// MaxRestarts is the number of failures after which the orchestrator
+	// stops restarting the component.  Zero means never restart.
+	MaxRestarts int
+
+	// Backoff is the delay before each restart, measured with the
+	// orchestrator's clock.
+	Backoff time.Duration
+}
+
+type panicHandlerKey struct{}
+
+// withPanicHandler returns a context carrying a function to be called when the
+// target of a request sent with that context panics while handling it.  The
+// function returns the error to report for the panic.
+func withPanicHandler(ctx context.Context, handler func(value interface{}, stack string) error) context.Context {
+	return context.WithValue(ctx, panicHandlerKey{}, handler)
+}
+
+type handledKey struct{}
+
+// withHandledHook returns a context carrying a function to be called once an
+// asynchronous request sent with that context has been handled from a
+// mailbox (see HandleEnvelope), whether or not it succeeded.
+func withHandledHook(ctx context.Context, hook func()) context.Context {
+	return context.WithValue(ctx, handledKey{}, hook)
+}
+
+// recovered converts a recovered panic value into an error, using the
+// panic handler in the context if there is one.  This must be called from
+// the deferred function that recovered the panic, so that the stack is that
+// of the panic.
+func recovered(ctx context.Context, value interface{}) error {
+	stack := string(debug.Stack())
+	if handler, ok := ctx.Value(panicHandlerKey{}).(func(interface{}, string) error); ok {
+		return handler(value, stack)
+	}
+	return &PanicError{Value: value, Stack: stack}
+}
+
+// HandleEnvelope handles a message taken from a component's mailbox by calling
+// handler (typically the component's own Request method), and reports any
+// error with ReportAsyncFailure.  A panic in handler is recovered and treated
+// like a panic in a request made through a reference: the component is
+// marked as failed, and the failure reported as a PanicError.
+//
+// Once the message is handled, any default timeout applied to it by the
+// orchestrator (see ComponentImpl#Timeouts) is released.
+func HandleEnvelope(env Envelope, handler func(context.Context, Message) (Message, error)) {
+	if hook, ok := env.Ctx.Value(handledKey{}).(func()); ok {
+		defer hook()
+	}
+	err := func() (err error) {
+		defer func() {
+			if value := recover(); value != nil {
+				err = recovered(env.Ctx, value)
+			}
+		}()
+		_, err = handler(env.Ctx, env.Msg)
+		return err
+	}()
+	if err != nil {
+		ReportAsyncFailure(env.Ctx, err)
+	}
+}
+
+// panicked handles a panic by the given instance of a component, returning
+// the error to report to the caller.
+func (orch *Orchestrator) panicked(path ComponentPath, generation int, value interface{}, stack string) error {
+	err := &PanicError{Component: path, Value: value, Stack: stack}
+	orch.recordError(path, err)
+	// this may be called while orch.mu is held (during Start), so the failure
+	// is handled in another goroutine
+	go orch.failed(path, generation)
+	return err
+}
+
+// failed marks the given instance of a component as failed, and applies its
+// restart policy.
+func (orch *Orchestrator) failed(path ComponentPath, generation int) {
+	orch.mu.Lock()
+	acomp, found := orch.active[path]
+	if !found || acomp.generation != generation || acomp.state != RunningState {
+		// already stopping, or failed, or replaced
+		orch.mu.Unlock()
+		return
+	}
+	acomp.state = FailedState
+	orch.active[path] = acomp
+	policy := orch.registered[path].Restart
+	orch.mu.Unlock()
+
+	orch.historyMu.Lock()
+	h := orch.historyFor(path)
+	h.failures++
+	failures := h.failures
+	orch.historyMu.Unlock()
+
+	if failures > policy.MaxRestarts {
+		return
+	}
+	if policy.Backoff > 0 {
+		orch.clock.Sleep(policy.Backoff)
+	}
+
+	orch.lifecycleMu.Lock()
+	defer orch.lifecycleMu.Unlock()
+
+	orch.mu.Lock()
+	acomp, found = orch.active[path]
+	stopTimeout := orch.stopTimeout
+	orch.mu.Unlock()
+	if !found || acomp.generation != generation || acomp.state != FailedState {
+		return
+	}
+
+	ctx, cancel := clock.WithTimeout(context.Background(), orch.clock, stopTimeout)
+	defer cancel()
+	if err := orch.restart(ctx, orch.dependents(path)); err != nil {
+		orch.recordError(path, fmt.Errorf("Restarting after failure: %w", err))
+	}
+}
+
+// failedComponent stands in for a component whose Start function panicked.
+type failedComponent struct {
+	err  error
+	done chan struct{}
+}
+
+var _ Component = &failedComponent{}
+
+func newFailedComponent(err error) *failedComponent {
+	done := make(chan struct{})
+	close(done)
+	return &failedComponent{err: err, done: done}
+}
+
+// NewReference implements Component#NewReference.
+func (fc *failedComponent) NewReference() ComponentReference {
+	return fc
+}
+
+// Done implements Component#Done.
+func (fc *failedComponent) Done() <-chan struct{} {
+	return fc.done
+}
+
+// Request implements ComponentReference#Request.
+func (fc *failedComponent) Request(ctx context.Context, msg Message) (Message, error) {
+	return nil, fc.err
+}
+
+// RequestAsync implements ComponentReference#RequestAsync.
+func (fc *failedComponent) RequestAsync(ctx context.Context, msg Message) {
+	ReportAsyncFailure(ctx, fc.err)
+}
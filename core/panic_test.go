@@ -0,0 +1,130 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/clock"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// panicky returns a FakeReference that panics on the message "panic".
+func panicky() *comptest.FakeReference {
+	fake := comptest.NewFakeReference()
+	fake.Handler = func(ctx context.Context, msg core.Message) (core.Message, error) {
+		if msg == "panic" {
+			panic("boom")
+		}
+		return "ok", nil
+	}
+	return fake
+}
+
+// startPanicky starts root, which depends on svc, a panicky component with
+// the given restart policy.  It returns the orchestrator and a function
+// returning the current root instance's reference to svc (root is restarted
+// along with svc).
+func startPanicky(t *testing.T, clk clock.Clock, policy core.RestartPolicy) (*core.Orchestrator, func() core.ComponentReference) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var ref core.ComponentReference
+	svc := fakeImpl("svc", panicky(), nil, nil)
+	svc.Restart = policy
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				mu.Lock()
+				defer mu.Unlock()
+				ref = deps["svc"]
+			}),
+		svc,
+	)
+	orch.SetClock(clk)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+	return orch, func() core.ComponentReference {
+		mu.Lock()
+		defer mu.Unlock()
+		return ref
+	}
+}
+
+// state returns a function reporting whether the component has the given state.
+func state(orch *core.Orchestrator, path core.ComponentPath, want core.ComponentState) func() bool {
+	return func() bool { return orch.Status()[path].State == want }
+}
+
+func TestRequestPanicFailsComponent(t *testing.T) {
+	orch, svc := startPanicky(t, clock.Real(), core.RestartPolicy{})
+	ref := svc()
+
+	_, err := ref.Request(context.Background(), "panic")
+	var panicErr *core.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("request returned %v, want a *core.PanicError", err)
+	}
+	if panicErr.Component != "svc" || panicErr.Value != "boom" || !strings.Contains(panicErr.Stack, "panicky") {
+		t.Errorf("panic error %+v", panicErr)
+	}
+
+	eventually(t, "svc to fail", state(orch, "svc", core.FailedState))
+	status := orch.Status()["svc"]
+	if status.Failures != 1 || status.Restarts != 0 || status.LastError != err {
+		t.Errorf("status %+v after one failure without a restart policy", status)
+	}
+	if rsp, err := ref.Request(context.Background(), "ping"); rsp != "ok" || err != nil {
+		t.Errorf("failed component returned %v, %v", rsp, err)
+	}
+}
+
+func TestStartPanicFailsComponent(t *testing.T) {
+	orch := core.NewOrchestrator(core.ComponentImpl{
+		Path: "svc",
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			panic("cannot start")
+		},
+	})
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	var panicErr *core.PanicError
+	if _, err := orch.Root.Request(context.Background(), "ping"); !errors.As(err, &panicErr) || panicErr.Value != "cannot start" {
+		t.Fatalf("request to a component whose Start panicked returned %v", err)
+	}
+	eventually(t, "svc to fail", state(orch, "svc", core.FailedState))
+}
+
+func TestRestartPolicy(t *testing.T) {
+	clk := clock.NewFake(comptest.FakeEpoch)
+	orch, svc := startPanicky(t, clk, core.RestartPolicy{MaxRestarts: 1, Backoff: time.Second})
+
+	svc().Request(context.Background(), "panic")
+	clk.BlockUntil(1)
+	if s := orch.Status()["svc"].State; s != core.FailedState {
+		t.Fatalf("svc is %s during backoff", s)
+	}
+	clk.Advance(time.Second)
+	eventually(t, "svc to restart", func() bool {
+		s := orch.Status()["svc"]
+		return s.State == core.RunningState && s.Restarts == 1
+	})
+	if rsp, err := svc().Request(context.Background(), "ping"); rsp != "ok" || err != nil {
+		t.Fatalf("restarted component returned %v, %v", rsp, err)
+	}
+
+	// the second failure exceeds MaxRestarts
+	svc().Request(context.Background(), "panic")
+	eventually(t, "svc to fail", state(orch, "svc", core.FailedState))
+	if clk.Waiters() != 0 {
+		t.Fatal("restart scheduled after MaxRestarts failures")
+	}
+}
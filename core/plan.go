@@ -0,0 +1,231 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Plan describes what Start would do, without starting anything, as returned
+// from Orchestrator#Plan.
+type Plan struct {
+	// Root is the path of the root component.
+	Root ComponentPath `json:"root"`
+
+	// StartOrder gives the components that would be started, in order, each
+	// after all of its dependencies.
+	StartOrder []ComponentPath `json:"startOrder"`
+
+	// StopOrder gives the same components in the order in which Stop would
+	// stop them, each before all of its dependencies.
+	StopOrder []ComponentPath `json:"stopOrder"`
+
+	// Unused gives the registered components that would not be started,
+	// because nothing started depends on them, sorted by path.
+	Unused []ComponentPath `json:"unused"`
+
+	// Problems lists everything that would prevent a successful start.
+	Problems []PlanProblem `json:"problems"`
+}
+
+// PlanProblemKind identifies the kind of a PlanProblem.  It is one of the
+// *Problem constants.
+type PlanProblemKind string
+
+// PlanProblemKind values
+const (
+	// MissingProblem indicates a dependency on a component that is not
+	// registered.
+	MissingProblem PlanProblemKind = "missing"
+
+	// ServiceProblem indicates a required service without a unique provider.
+	ServiceProblem PlanProblemKind = "service"
+
+	// CycleProblem indicates a dependency cycle.
+	CycleProblem PlanProblemKind = "cycle"
+
+	// ConfigProblem indicates configuration that cannot be loaded, or that a
+	// component rejects (see ComponentImpl#CheckConfig), or that is given for
+	// a component that is not registered.
+	ConfigProblem PlanProblemKind = "config"
+)
+
+// PlanProblem is a single problem in a Plan.
+type PlanProblem struct {
+	// Kind is the kind of problem.
+	Kind PlanProblemKind `json:"kind"`
+
+	// Component is the component with the problem, if there is one.
+	Component ComponentPath `json:"component,omitempty"`
+
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+func (p PlanProblem) String() string {
+	if p.Component != "" {
+		return fmt.Sprintf("%s: %s: %s", p.Kind, p.Component, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Kind, p.Message)
+}
+
+// Err returns an error listing the plan's problems, or nil if there are none.
+func (p Plan) Err() error {
+	errs := []error{}
+	for _, problem := range p.Problems {
+		errs = append(errs, errors.New(problem.String()))
+	}
+	return errors.Join(errs...)
+}
+
+// String formats the plan as a human-readable report.
+func (p Plan) String() string {
+	lines := []string{fmt.Sprintf("Root: %s", p.Root), "Start order:"}
+	for i, path := range p.StartOrder {
+		lines = append(lines, fmt.Sprintf("  %d. %s", i+1, path))
+	}
+	lines = append(lines, "Stop order:")
+	for i, path := range p.StopOrder {
+		lines = append(lines, fmt.Sprintf("  %d. %s", i+1, path))
+	}
+	if len(p.Unused) > 0 {
+		lines = append(lines, "Registered but unused:")
+		for _, path := range p.Unused {
+			lines = append(lines, "  "+string(path))
+		}
+	}
+	if len(p.Problems) > 0 {
+		lines = append(lines, "Problems:")
+		for _, problem := range p.Problems {
+			lines = append(lines, "  "+problem.String())
+		}
+	} else {
+		lines = append(lines, "No problems found.")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Plan determines what Start would do, without starting anything: the order
+// in which components would start and stop, which registered components
+// would be unused, and any problems that would prevent a successful start.
+// The configuration is loaded from the config source to check it, but is not
+// applied.
+func (orch *Orchestrator) Plan() Plan {
+	orch.configMu.Lock()
+	source := orch.configSource
+	orch.configMu.Unlock()
+
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	plan := Plan{
+		Root:       orch.RootPath,
+		StartOrder: []ComponentPath{},
+		StopOrder:  []ComponentPath{},
+		Unused:     []ComponentPath{},
+		Problems:   []PlanProblem{},
+	}
+	problem := func(kind PlanProblemKind, path ComponentPath, format string, args ...interface{}) {
+		plan.Problems = append(plan.Problems, PlanProblem{Kind: kind, Component: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// walk the graph from the root, as Start would, noting each component
+	// once all of its dependencies have been noted
+	state := map[ComponentPath]int{} // 1: in progress, 2: done
+	stack := []ComponentPath{}
+	var visit func(path ComponentPath)
+	visit = func(path ComponentPath) {
+		switch state[path] {
+		case 1:
+			cycle := []string{}
+			for i := len(stack) - 1; i >= 0; i-- {
+				cycle = append([]string{string(stack[i])}, cycle...)
+				if stack[i] == path {
+					break
+				}
+			}
+			problem(CycleProblem, path, "%s -> %s", strings.Join(cycle, " -> "), path)
+			return
+		case 2:
+			return
+		}
+		state[path] = 1
+		stack = append(stack, path)
+
+		compImpl := orch.registered[path]
+		deps := []ComponentPath{}
+		for _, dep := range compImpl.Dependencies {
+			if _, found := orch.registered[dep]; !found {
+				problem(MissingProblem, path, "depends on %s, which is not registered", dep)
+				continue
+			}
+			deps = append(deps, dep)
+		}
+		providers, err := orch.serviceProviders(path)
+		if err != nil {
+			problem(ServiceProblem, path, "%s", err)
+		}
+		services := []Service{}
+		for service := range providers {
+			services = append(services, service)
+		}
+		sort.Slice(services, func(i, j int) bool { return services[i] < services[j] })
+		for _, service := range services {
+			deps = append(deps, providers[service]...)
+		}
+		for _, dep := range deps {
+			visit(dep)
+		}
+
+		stack = stack[:len(stack)-1]
+		state[path] = 2
+		plan.StartOrder = append(plan.StartOrder, path)
+	}
+	if _, found := orch.registered[orch.RootPath]; found {
+		visit(orch.RootPath)
+	} else {
+		problem(MissingProblem, "", "root component %s is not registered", orch.RootPath)
+	}
+
+	for i := len(plan.StartOrder) - 1; i >= 0; i-- {
+		plan.StopOrder = append(plan.StopOrder, plan.StartOrder[i])
+	}
+	for path := range orch.registered {
+		if state[path] == 0 {
+			plan.Unused = append(plan.Unused, path)
+		}
+	}
+	sort.Slice(plan.Unused, func(i, j int) bool { return plan.Unused[i] < plan.Unused[j] })
+
+	if source != nil {
+		config, err := source()
+		if err != nil {
+			problem(ConfigProblem, "", "%s", err)
+		}
+		paths := []ComponentPath{}
+		for path := range config {
+			paths = append(paths, path)
+		}
+		sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+		for _, path := range paths {
+			compImpl, found := orch.registered[path]
+			if !found {
+				problem(ConfigProblem, path, "configured, but not registered")
+				continue
+			}
+			if compImpl.CheckConfig != nil {
+				if err := compImpl.CheckConfig(config[path]); err != nil {
+					problem(ConfigProblem, path, "%s", err)
+				}
+			}
+		}
+	}
+	return plan
+}
+
+// Validate checks that Start can succeed, returning an error listing every
+// problem found by Plan, or nil if there are none.
+func (orch *Orchestrator) Validate() error {
+	return orch.Plan().Err()
+}
@@ -0,0 +1,136 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func impl(path core.ComponentPath, deps ...core.ComponentPath) core.ComponentImpl {
+	return fakeImpl(path, comptest.NewFakeReference(), deps, nil)
+}
+
+// problems returns the plan's problems of the given kind.
+func problems(plan core.Plan, kind core.PlanProblemKind) []core.PlanProblem {
+	rv := []core.PlanProblem{}
+	for _, p := range plan.Problems {
+		if p.Kind == kind {
+			rv = append(rv, p)
+		}
+	}
+	return rv
+}
+
+func TestPlanOrder(t *testing.T) {
+	logger := impl("logger")
+	logger.Provides = []core.Service{"log"}
+	root := impl("root", "db")
+	root.Requires = []core.Service{"log"}
+	orch := core.NewOrchestrator(root, impl("db", "logger"), logger, impl("spare"))
+
+	plan := orch.Plan()
+	if len(plan.Problems) != 0 {
+		t.Fatalf("unexpected problems: %v", plan.Problems)
+	}
+	if want := []core.ComponentPath{"logger", "db", "root"}; !reflect.DeepEqual(plan.StartOrder, want) {
+		t.Errorf("start order %v, want %v", plan.StartOrder, want)
+	}
+	if want := []core.ComponentPath{"root", "db", "logger"}; !reflect.DeepEqual(plan.StopOrder, want) {
+		t.Errorf("stop order %v, want %v", plan.StopOrder, want)
+	}
+	if want := []core.ComponentPath{"spare"}; !reflect.DeepEqual(plan.Unused, want) {
+		t.Errorf("unused %v, want %v", plan.Unused, want)
+	}
+	if err := orch.Validate(); err != nil {
+		t.Errorf("Validate: %s", err)
+	}
+}
+
+func TestPlanFindsCycle(t *testing.T) {
+	orch := core.NewOrchestrator(impl("root", "a"), impl("a", "b"), impl("b", "a"))
+
+	cycles := problems(orch.Plan(), core.CycleProblem)
+	if len(cycles) != 1 || cycles[0].Message != "a -> b -> a" {
+		t.Fatalf("cycle problems %v, want one for a -> b -> a", cycles)
+	}
+	if err := orch.Validate(); err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Fatalf("Validate returned %v", err)
+	}
+}
+
+func TestPlanFindsMissingComponentsAndProviders(t *testing.T) {
+	root := impl("root", "db")
+	root.Requires = []core.Service{"log"}
+	orch := core.NewOrchestrator(root)
+
+	plan := orch.Plan()
+	missing := problems(plan, core.MissingProblem)
+	if len(missing) != 1 || missing[0].Component != "root" || !strings.Contains(missing[0].Message, "db") {
+		t.Errorf("missing problems %v, want one for root's dependency on db", missing)
+	}
+	services := problems(plan, core.ServiceProblem)
+	if len(services) != 1 || services[0].Component != "root" || !strings.Contains(services[0].Message, "No component provides log") {
+		t.Errorf("service problems %v, want one for root's log provider", services)
+	}
+}
+
+func TestPlanFindsAmbiguousProvider(t *testing.T) {
+	a, b := impl("a"), impl("b")
+	a.Provides = []core.Service{"log"}
+	b.Provides = []core.Service{"log"}
+	root := impl("root")
+	root.Requires = []core.Service{"log"}
+	orch := core.NewOrchestrator(root, a, b)
+
+	if services := problems(orch.Plan(), core.ServiceProblem); len(services) != 1 {
+		t.Fatalf("service problems %v, want one", services)
+	}
+	orch.SetProvider("log", "b")
+	plan := orch.Plan()
+	if len(plan.Problems) != 0 {
+		t.Fatalf("problems after SetProvider: %v", plan.Problems)
+	}
+	if want := []core.ComponentPath{"b", "root"}; !reflect.DeepEqual(plan.StartOrder, want) {
+		t.Errorf("start order %v, want %v", plan.StartOrder, want)
+	}
+}
+
+func TestPlanChecksConfig(t *testing.T) {
+	root := impl("root")
+	root.CheckConfig = func(config core.Config) error {
+		var settings struct{ Port int }
+		if err := config.Decode(&settings); err != nil {
+			return err
+		}
+		if settings.Port == 0 {
+			return errors.New("no port")
+		}
+		return nil
+	}
+	orch := core.NewOrchestrator(root)
+	source := &configs{}
+	orch.SetConfigSource(source.source)
+
+	source.set(map[core.ComponentPath]core.Config{"root": core.Config(`{"Port": 80}`)}, nil)
+	if err := orch.Validate(); err != nil {
+		t.Errorf("Validate: %s", err)
+	}
+
+	source.set(map[core.ComponentPath]core.Config{"root": core.Config(`{}`), "stray": core.Config(`{}`)}, nil)
+	got := []string{}
+	for _, p := range problems(orch.Plan(), core.ConfigProblem) {
+		got = append(got, p.String())
+	}
+	want := []string{"config: root: no port", "config: stray: configured, but not registered"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config problems %q, want %q", got, want)
+	}
+
+	source.set(nil, errors.New("unreadable"))
+	if err := orch.Validate(); err == nil || !strings.Contains(err.Error(), "unreadable") {
+		t.Errorf("Validate with a failing config source returned %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Reconfigure is a Message sent by the orchestrator to a component whose
+// configuration has changed.  A component that can apply the new
+// configuration in place should do so and return (nil, nil).  If it returns
+// any error (including the usual error for unrecognized messages), the
+// orchestrator restarts it, along with everything that depends on it.
+type Reconfigure struct {
+	// Config is the component's new configuration.
+	Config Config
+}
+
+// ReconfigureOutcome describes what happened to a component during
+// reconfiguration.  It is one of the *Outcome constants.
+type ReconfigureOutcome string
+
+// ReconfigureOutcome values
+const (
+	// ReconfiguredOutcome indicates that the component applied its new
+	// configuration in place.
+	ReconfiguredOutcome ReconfigureOutcome = "reconfigured"
+
+	// RestartedOutcome indicates that the component was restarted, either
+	// because it could not apply its new configuration in place or because
+	// one of its dependencies was restarted.
+	RestartedOutcome ReconfigureOutcome = "restarted"
+
+	// FailedOutcome indicates that the component could not be restarted.
+	FailedOutcome ReconfigureOutcome = "failed"
+)
+
+// ReconfigureStep reports the outcome of one step of reconfiguration.
+type ReconfigureStep struct {
+	// Path is the component affected by this step.
+	Path ComponentPath
+
+	// Outcome is what happened to the component.
+	Outcome ReconfigureOutcome
+
+	// Err is the error that caused a restart or a failure, if any.
+	Err error
+}
+
+func (step ReconfigureStep) String() string {
+	if step.Err != nil {
+		return fmt.Sprintf("%s: %s (%s)", step.Path, step.Outcome, step.Err)
+	}
+	return fmt.Sprintf("%s: %s", step.Path, step.Outcome)
+}
+
+// Reconfigure re-reads the configuration from the config source and delivers
+// a Reconfigure message to each active component whose configuration has
+// changed, dependencies first.  Components that cannot handle the message,
+// panic while handling it, or take longer than the stop timeout (see
+// SetStopTimeout) are restarted along with their dependents.  The context
+// limits the time allowed for those components to stop.
+//
+// The returned steps describe what happened to each affected component.  An
+// error is returned if the configuration cannot be loaded (in which case
+// nothing changes) or if any component fails to restart.
+func (orch *Orchestrator) Reconfigure(ctx context.Context) ([]ReconfigureStep, error) {
+	orch.lifecycleMu.Lock()
+	defer orch.lifecycleMu.Unlock()
+
+	old, err := orch.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []ReconfigureStep{}
+	restart := map[ComponentPath]bool{}
+	for _, path := range orch.startOrder() {
+		newConfig := orch.Config(path)
+		if newConfig.Equal(old[path]) || restart[path] {
+			continue
+		}
+
+		err := orch.reconfigure(ctx, path, newConfig)
+		if err == nil {
+			steps = append(steps, ReconfigureStep{Path: path, Outcome: ReconfiguredOutcome})
+			continue
+		}
+		steps = append(steps, ReconfigureStep{Path: path, Outcome: RestartedOutcome, Err: err})
+		for dep := range orch.dependents(path) {
+			restart[dep] = true
+		}
+	}
+
+	if len(restart) == 0 {
+		return steps, nil
+	}
+
+	// report dependents that were restarted along with the failing components
+	for _, path := range orch.startOrder() {
+		if !restart[path] {
+			continue
+		}
+		found := false
+		for _, step := range steps {
+			found = found || step.Path == path
+		}
+		if !found {
+			steps = append(steps, ReconfigureStep{Path: path, Outcome: RestartedOutcome})
+		}
+	}
+
+	if err := orch.restart(ctx, restart); err != nil {
+		for i := range steps {
+			if restart[steps[i].Path] {
+				steps[i].Outcome = FailedOutcome
+				steps[i].Err = err
+			}
+		}
+		return steps, err
+	}
+	return steps, nil
+}
+
+// reconfigure sends a Reconfigure message to an active component.  Like a
+// request through any other reference, a panic is a failure of the component,
+// and if the context has no deadline, the stop timeout applies.  The
+// orchestrator is not subject to the component's access policy.
+func (orch *Orchestrator) reconfigure(ctx context.Context, path ComponentPath, config Config) error {
+	orch.mu.Lock()
+	ref := &reference{
+		orch:    orch,
+		target:  path,
+		binding: orch.binding(path),
+		timeout: orch.stopTimeout,
+	}
+	orch.mu.Unlock()
+
+	reqCtx, cancel, _ := ref.withDefaultTimeout(ctx)
+	defer cancel()
+	_, err := ref.attempt(reqCtx, ctx, Reconfigure{Config: config})
+	return err
+}
+
+// dependents returns the given component and every active component that
+// depends on it, directly or indirectly.
+func (orch *Orchestrator) dependents(path ComponentPath) map[ComponentPath]bool {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	rv := map[ComponentPath]bool{path: true}
+	for changed := true; changed; {
+		changed = false
+		for p := range orch.active {
+			if rv[p] {
+				continue
+			}
+			for _, dep := range orch.dependencies(p) {
+				if rv[dep] {
+					rv[p] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return rv
+}
+
+// restart stops the given components, which must include all of their
+// dependents, and then starts them again.  Snapshot errors do not prevent the
+// restart, but are returned.
+func (orch *Orchestrator) restart(ctx context.Context, paths map[ComponentPath]bool) error {
+	errs := []error{}
+	order := orch.startOrder()
+	for i := len(order) - 1; i >= 0; i-- {
+		if paths[order[i]] {
+			if err := orch.stopComponent(ctx, order[i]); err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	for path := range paths {
+		delete(orch.active, path)
+		delete(orch.bindings, path)
+	}
+	root, err := orch.getComponentReference(orch.RootPath)
+	if err != nil {
+		return err
+	}
+	orch.Root = root
+	return errors.Join(errs...)
+}
@@ -0,0 +1,216 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// configs is a mutable ConfigSource.
+type configs struct {
+	mu     sync.Mutex
+	config map[core.ComponentPath]core.Config
+	err    error
+}
+
+func (c *configs) set(config map[core.ComponentPath]core.Config, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config, c.err = config, err
+}
+
+func (c *configs) source() (map[core.ComponentPath]core.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.config, c.err
+}
+
+func TestConfigDecodeAndEqual(t *testing.T) {
+	settings := struct{ Address, Level string }{Address: "default", Level: "info"}
+	if err := core.Config(`{"Level": "debug"}`).Decode(&settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.Address != "default" || settings.Level != "debug" {
+		t.Errorf("decoded %+v", settings)
+	}
+	if err := core.Config(nil).Decode(&settings); err != nil {
+		t.Errorf("empty config: %s", err)
+	}
+
+	if !core.Config(`{"a": 1}`).Equal(core.Config(`{"a":1}`)) {
+		t.Error("configs differing only in whitespace are not equal")
+	}
+	if core.Config(`{"a": 1}`).Equal(core.Config(`{"a": 2}`)) {
+		t.Error("different configs are equal")
+	}
+}
+
+// startConfigured starts root, which depends on a, which depends on b.  b
+// applies new configuration in place, while a rejects it.  It returns the
+// orchestrator and the configurations with which a has been started.
+func startConfigured(t *testing.T, source *configs) (*core.Orchestrator, func() []core.Config) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var started []core.Config
+	var orch *core.Orchestrator
+
+	a := comptest.NewFakeReference()
+	a.Handler = func(ctx context.Context, msg core.Message) (core.Message, error) {
+		return nil, errors.New("cannot reconfigure")
+	}
+	orch = core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"a"}, nil),
+		fakeImpl("a", a, []core.ComponentPath{"b"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				mu.Lock()
+				defer mu.Unlock()
+				started = append(started, orch.Config("a"))
+			}),
+		fakeImpl("b", comptest.NewFakeReference(), nil, nil),
+	)
+	orch.SetConfigSource(source.source)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+
+	return orch, func() []core.Config {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]core.Config{}, started...)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	source := &configs{}
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{"x": 1}`), "b": core.Config(`{"y": 1}`)}, nil)
+	orch, started := startConfigured(t, source)
+
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{"x": 2}`), "b": core.Config(`{"y": 2}`)}, nil)
+	steps, err := orch.Reconfigure(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes := map[core.ComponentPath]core.ReconfigureOutcome{}
+	for _, step := range steps {
+		outcomes[step.Path] = step.Outcome
+	}
+	want := map[core.ComponentPath]core.ReconfigureOutcome{
+		"b":    core.ReconfiguredOutcome,
+		"a":    core.RestartedOutcome,
+		"root": core.RestartedOutcome,
+	}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("outcomes %v, want %v", outcomes, want)
+	}
+
+	configs := started()
+	if len(configs) != 2 || string(configs[1]) != `{"x": 2}` {
+		t.Errorf("a started with %q", configs)
+	}
+	for path, s := range orch.Status() {
+		if s.State != core.RunningState {
+			t.Errorf("%s is %s after reconfiguring", path, s.State)
+		}
+	}
+}
+
+func TestReconfigureUnchanged(t *testing.T) {
+	source := &configs{}
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{"x": 1}`)}, nil)
+	orch, started := startConfigured(t, source)
+
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{ "x":1 }`)}, nil)
+	steps, err := orch.Reconfigure(context.Background())
+	if err != nil || len(steps) != 0 {
+		t.Errorf("Reconfigure returned %v, %v", steps, err)
+	}
+	if n := len(started()); n != 1 {
+		t.Errorf("a started %d times", n)
+	}
+}
+
+func TestReconfigureLoadFailure(t *testing.T) {
+	source := &configs{}
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{"x": 1}`)}, nil)
+	orch, started := startConfigured(t, source)
+
+	source.set(nil, errors.New("unreadable"))
+	if _, err := orch.Reconfigure(context.Background()); err == nil {
+		t.Error("Reconfigure succeeded despite the failing config source")
+	}
+	if got := string(orch.Config("a")); got != `{"x": 1}` {
+		t.Errorf("config changed to %q", got)
+	}
+	if n := len(started()); n != 1 {
+		t.Errorf("a started %d times", n)
+	}
+}
+
+// startReconfigurable starts an orchestrator with a single component, "a",
+// that handles Reconfigure messages with handler.
+func startReconfigurable(t *testing.T, source *configs, handler func(context.Context, core.Message) (core.Message, error)) *core.Orchestrator {
+	t.Helper()
+
+	a := comptest.NewFakeReference()
+	a.Handler = handler
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"a"}, nil),
+		fakeImpl("a", a, nil, nil),
+	)
+	orch.SetConfigSource(source.source)
+	orch.SetStopTimeout(10 * time.Millisecond)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+	return orch
+}
+
+func TestReconfigurePanic(t *testing.T) {
+	source := &configs{}
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{"x": 1}`)}, nil)
+	orch := startReconfigurable(t, source, func(ctx context.Context, msg core.Message) (core.Message, error) {
+		panic("cannot cope")
+	})
+
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{"x": 2}`)}, nil)
+	steps, err := orch.Reconfigure(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var panicErr *core.PanicError
+	if len(steps) == 0 || steps[0].Path != "a" || !errors.As(steps[0].Err, &panicErr) {
+		t.Fatalf("steps %v, want a restarted after a panic", steps)
+	}
+	if steps[0].Outcome != core.RestartedOutcome {
+		t.Errorf("a was %s", steps[0].Outcome)
+	}
+}
+
+func TestReconfigureTimeout(t *testing.T) {
+	source := &configs{}
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{"x": 1}`)}, nil)
+	orch := startReconfigurable(t, source, func(ctx context.Context, msg core.Message) (core.Message, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	source.set(map[core.ComponentPath]core.Config{"a": core.Config(`{"x": 2}`)}, nil)
+	steps, err := orch.Reconfigure(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) == 0 || steps[0].Path != "a" || !errors.Is(steps[0].Err, context.DeadlineExceeded) {
+		t.Fatalf("steps %v, want a restarted after a timeout", steps)
+	}
+}
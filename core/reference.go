@@ -0,0 +1,248 @@
+package core
+
+import (
+	"comps/core/clock"
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// instance is a running instance of a component, as seen by the references
+// to it.
+type instance struct {
+	comp       Component
+	generation int
+
+	// access is the access policy of the component's implementation
+	access AccessPolicy
+
+	// inflight counts requests made through references to this instance that
+	// have not yet returned
+	inflight sync.WaitGroup
+}
+
+// binding holds the current instance of a component.  It is shared by every
+// reference to that component, so that Replace can switch them all at once.
+type binding struct {
+	// mu is held for writing while the instance is being replaced, blocking
+	// new requests until the replacement is in place
+	mu   sync.RWMutex
+	inst *instance
+
+	// done is closed, by retire, when the component begins stopping
+	done    chan struct{}
+	retired sync.Once
+}
+
+// retire closes the binding's done channel.
+func (b *binding) retire() {
+	b.retired.Do(func() { close(b.done) })
+}
+
+// acquire returns the current instance, counting a request in flight.  The
+// caller must call inst.inflight.Done() when the request returns.
+func (b *binding) acquire() *instance {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	b.inst.inflight.Add(1)
+	return b.inst
+}
+
+// access returns the access policy of the current instance.
+func (b *binding) access() AccessPolicy {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.inst.access
+}
+
+// reference wraps the ComponentReference handed to a dependent component,
+// so that the orchestrator knows the caller and target of each request.
+type reference struct {
+	orch    *Orchestrator
+	caller  ComponentPath
+	target  ComponentPath
+	binding *binding
+
+	// timeout is the default timeout for requests without a deadline, or
+	// zero for none
+	timeout time.Duration
+
+	// retry is the policy for retrying requests with Idempotent messages
+	retry RetryPolicy
+
+	// breaker is the circuit breaker for this reference, or nil
+	breaker *breaker
+
+	// mu protects inst and ref, the reference to the instance last used
+	mu   sync.Mutex
+	inst *instance
+	ref  ComponentReference
+}
+
+var _ ComponentReference = &reference{}
+
+// Done returns a channel that is closed when the target component begins
+// stopping, after which requests through this reference go to a stopped
+// instance.  It is not closed when the target is replaced (see Replace), since
+// the reference then refers to the new instance.  Like the Done method of a
+// Component, this lets the recipient of a reference, such as
+// `core/comp/bus.Main`, tell when it is no longer useful.
+func (r *reference) Done() <-chan struct{} {
+	return r.binding.done
+}
+
+// current returns the target's current instance, and a reference to it.
+func (r *reference) current() (*instance, ComponentReference) {
+	inst := r.binding.acquire()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if inst != r.inst {
+		r.inst = inst
+		r.ref = inst.comp.NewReference()
+	}
+	return inst, r.ref
+}
+
+type callerKey struct{}
+
+// withCaller returns a context identifying the component making a request.
+func withCaller(ctx context.Context, caller ComponentPath) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// Caller returns the path of the component that sent the request with the
+// given context, and whether that is known.  Every request made through a
+// reference given to a component by the orchestrator carries its caller,
+// including when the message is later taken from a mailbox.  Requests made
+// through Orchestrator#Root, or directly to a component, do not.
+func Caller(ctx context.Context) (ComponentPath, bool) {
+	caller, ok := ctx.Value(callerKey{}).(ComponentPath)
+	return caller, ok
+}
+
+// withDefaultTimeout applies the reference's default timeout to a context
+// without a deadline.  The returned function reports whether the request
+// failed because that timeout expired.
+func (r *reference) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc, func() bool) {
+	if _, found := ctx.Deadline(); r.timeout == 0 || found {
+		return ctx, func() {}, func() bool { return false }
+	}
+	ctx, cancel := clock.WithTimeout(ctx, r.orch.clock, r.timeout)
+	return ctx, cancel, func() bool { return ctx.Err() == context.DeadlineExceeded }
+}
+
+// Request implements ComponentReference#Request.
+func (r *reference) Request(ctx context.Context, msg Message) (Message, error) {
+	ctx = withCaller(ctx, r.caller)
+	if err := r.orch.checkAccess(r.binding.access(), r.caller, r.target, msg); err != nil {
+		return nil, err
+	}
+	callerCtx := ctx
+	ctx, cancel, timedOut := r.withDefaultTimeout(ctx)
+	defer cancel()
+
+	policy := RetryPolicy{}
+	if _, ok := msg.(Idempotent); ok {
+		policy = r.retry
+	}
+	rsp, err := r.orch.retry(ctx, policy, func() (Message, error) {
+		return r.attempt(ctx, callerCtx, msg)
+	})
+	if err != nil && !errors.Is(err, ErrCircuitOpen) {
+		r.orch.recordError(r.target, err)
+		if timedOut() {
+			r.orch.recordTimeout(r.caller, r.target)
+		}
+	}
+	return rsp, err
+}
+
+// attempt makes a single attempt at a synchronous request, subject to the
+// circuit breaker.  The caller's context, without the default timeout,
+// determines whether a failure was the caller's doing.
+func (r *reference) attempt(ctx, callerCtx context.Context, msg Message) (rsp Message, err error) {
+	if r.breaker != nil {
+		if err := r.breaker.allow(true); err != nil {
+			return nil, err
+		}
+		defer func() {
+			r.breaker.done(err, callerCtx.Err() == nil)
+		}()
+	}
+
+	inst, ref := r.current()
+	defer inst.inflight.Done()
+
+	withComponentLabel(ctx, r.target, r.caller, func(ctx context.Context) {
+		defer func() {
+			if value := recover(); value != nil {
+				err = r.orch.panicked(r.target, inst.generation, value, string(debug.Stack()))
+			}
+		}()
+		rsp, err = ref.Request(ctx, msg)
+	})
+	return rsp, err
+}
+
+// RequestAsync implements ComponentReference#RequestAsync.
+func (r *reference) RequestAsync(ctx context.Context, msg Message) {
+	ctx = withCaller(ctx, r.caller)
+
+	// the message may be handled after this returns, so the timeout context
+	// is cancelled once it is handled from the target's mailbox, or once it
+	// fails.  The timeout starts now, so it includes the time the message
+	// waits in the mailbox.  If the target neither uses HandleEnvelope nor
+	// reports a failure, the context is released only when it expires.
+	ctx, cancel, timedOut := r.withDefaultTimeout(ctx)
+	ctx = withHandledHook(ctx, cancel)
+	ctx = withAsyncFailureHandler(ctx, func(err error) {
+		var denied *AccessDeniedError
+		if !errors.Is(err, ErrCircuitOpen) && !errors.As(err, &denied) {
+			r.orch.recordError(r.target, err)
+		}
+		if timedOut() {
+			r.orch.recordTimeout(r.caller, r.target)
+		}
+		cancel()
+		r.orch.deadLetter(DeadLetter{
+			Caller:  r.caller,
+			Target:  r.target,
+			Message: msg,
+			Err:     err,
+		})
+	})
+
+	if err := r.orch.checkAccess(r.binding.access(), r.caller, r.target, msg); err != nil {
+		ReportAsyncFailure(ctx, err)
+		return
+	}
+	if r.breaker != nil {
+		if err := r.breaker.allow(false); err != nil {
+			ReportAsyncFailure(ctx, err)
+			return
+		}
+	}
+
+	inst, ref := r.current()
+	defer inst.inflight.Done()
+
+	// panics while the message is handled, whether now or later from a
+	// mailbox (see HandleEnvelope), are failures of the target
+	ctx = withPanicHandler(ctx, func(value interface{}, stack string) error {
+		return r.orch.panicked(r.target, inst.generation, value, stack)
+	})
+	withComponentLabel(ctx, r.target, r.caller, func(ctx context.Context) {
+		defer func() {
+			if value := recover(); value != nil {
+				ReportAsyncFailure(ctx, recovered(ctx, value))
+			}
+		}()
+		ref.RequestAsync(ctx, msg)
+	})
+}
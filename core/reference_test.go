@@ -0,0 +1,98 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/clock"
+	"comps/core/comptest"
+	"context"
+	"testing"
+	"time"
+)
+
+// isDone reports whether the reference's Done channel is closed.
+func isDone(ref core.ComponentReference) bool {
+	select {
+	case <-ref.(interface{ Done() <-chan struct{} }).Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestReferenceDone(t *testing.T) {
+	orch, ref := replaceFixture(t, comptest.NewFakeReference())
+	if isDone(ref) {
+		t.Fatal("reference done while its target runs")
+	}
+
+	if err := orch.Replace(context.Background(), fakeImpl("svc", comptest.NewFakeReference(), nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if isDone(ref) {
+		t.Fatal("reference done after its target was replaced")
+	}
+
+	stop(t, orch)
+	if !isDone(ref) {
+		t.Fatal("reference not done after its target stopped")
+	}
+}
+
+// timeoutFixture starts a root component depending on "svc", a mailbox
+// component handling messages with handle, with a one-minute default timeout
+// and a fake clock.  It returns root's reference to svc.
+func timeoutFixture(t *testing.T, handle func(context.Context, core.Message)) (*clock.Fake, core.ComponentReference) {
+	t.Helper()
+
+	var ref core.ComponentReference
+	root := fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+		func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+			ref = deps["svc"]
+		})
+	root.Timeouts = map[core.ComponentPath]time.Duration{"svc": time.Minute}
+	orch := core.NewOrchestrator(root, mailboxImpl("svc", handle))
+	clk := clock.NewFake(comptest.FakeEpoch)
+	orch.SetClock(clk)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+	return clk, ref
+}
+
+func TestRequestAsyncReleasesTimeout(t *testing.T) {
+	handled := make(chan context.Context, 1)
+	clk, ref := timeoutFixture(t, func(ctx context.Context, msg core.Message) { handled <- ctx })
+
+	ref.RequestAsync(context.Background(), "ping")
+	ctx := <-handled
+	eventually(t, "the timeout to be released", func() bool { return clk.Waiters() == 0 })
+	if err := ctx.Err(); err != context.Canceled {
+		t.Fatalf("context after handling is %v, want Canceled", err)
+	}
+}
+
+func TestRequestAsyncTimeoutIncludesQueuedTime(t *testing.T) {
+	release := make(chan struct{})
+	handled := make(chan context.Context, 2)
+	clk, ref := timeoutFixture(t, func(ctx context.Context, msg core.Message) {
+		if msg == "first" {
+			<-release
+		} else {
+			// the fake clock expires the context asynchronously
+			<-ctx.Done()
+		}
+		handled <- ctx
+	})
+
+	ref.RequestAsync(context.Background(), "first")
+	ref.RequestAsync(context.Background(), "second")
+	clk.BlockUntil(2)
+	clk.Advance(time.Minute)
+	close(release)
+
+	<-handled
+	if err := (<-handled).Err(); err != context.DeadlineExceeded {
+		t.Fatalf("queued message was handled with %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Replace replaces the running instance of a component with an instance of
+// newImpl, which must have the same path, without restarting the components
+// that depend on it.  This allows upgrading a component implementation in a
+// running process.
+//
+// Replace drains the old instance by waiting for requests in flight to return
+// and for its mailbox (if any) to empty.  Requests may still arrive while it
+// drains, so that a component handling a request can call one that calls it
+// back, as `comp/conns.Main` and `comp/users.Main` do.  Replace then blocks
+// new requests to the component, and drains whatever arrived in the meantime.
+// If the old instance implements Snapshotter, its snapshot is handed to the
+// new instance, which retrieves it with Host#Snapshot.  If it implements
+// HandoffComponent, its in-memory state is handed over as well, and the new
+// instance retrieves it with Host#Handoff.  The new instance is then started
+// (along with any new dependencies), every reference held by dependents is
+// switched to it at once, and the blocked requests proceed.  Finally, the old
+// instance is stopped.
+//
+// The context limits the time allowed for draining and stopping the old
+// instance.  If draining or starting fails, including if the new instance's
+// Start function panics, the old instance remains in place, any dependencies
+// started for the new instance are stopped, and the error is returned.
+func (orch *Orchestrator) Replace(ctx context.Context, newImpl ComponentImpl) error {
+	orch.lifecycleMu.Lock()
+	defer orch.lifecycleMu.Unlock()
+
+	path := newImpl.Path
+	orch.mu.Lock()
+	old, found := orch.active[path]
+	oldImpl := orch.registered[path]
+	b := orch.binding(path)
+	orch.mu.Unlock()
+	if !found {
+		return fmt.Errorf("Component %s is not active", path)
+	}
+
+	if err := drain(ctx, b.inst); err != nil {
+		return fmt.Errorf("Draining %s: %w", path, err)
+	}
+	b.mu.Lock()
+	if err := drain(ctx, b.inst); err != nil {
+		b.mu.Unlock()
+		return fmt.Errorf("Draining %s: %w", path, err)
+	}
+	if err := orch.snapshot(path, old.comp); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+
+	if hc, ok := old.comp.(HandoffComponent); ok {
+		orch.snapshotMu.Lock()
+		orch.handoff[path] = hc.Handoff()
+		orch.snapshotMu.Unlock()
+	}
+	defer func() {
+		orch.snapshotMu.Lock()
+		delete(orch.handoff, path)
+		orch.snapshotMu.Unlock()
+	}()
+
+	orch.mu.Lock()
+	wasActive := map[ComponentPath]bool{}
+	for p := range orch.active {
+		wasActive[p] = true
+	}
+	orch.registered[path] = newImpl
+	delete(orch.active, path)
+	ref, err := orch.getComponentReference(path)
+	if fc, ok := orch.active[path].comp.(*failedComponent); ok && err == nil {
+		// Start panicked; its failure handling finds the old generation
+		// active again, and does nothing
+		err = fmt.Errorf("Starting %s: %w", path, fc.err)
+	}
+	if err != nil {
+		if acomp, found := orch.active[path]; found {
+			acomp.stop()
+		}
+		started := map[ComponentPath]bool{}
+		for p := range orch.active {
+			if !wasActive[p] {
+				started[p] = true
+			}
+		}
+		orch.registered[path] = oldImpl
+		orch.active[path] = old
+		orch.mu.Unlock()
+		b.mu.Unlock()
+		if stopErr := orch.stopStarted(ctx, started); stopErr != nil {
+			return fmt.Errorf("%w (and stopping its new dependencies: %s)", err, stopErr)
+		}
+		return err
+	}
+	if path == orch.RootPath {
+		orch.Root = ref
+	}
+	acomp := orch.active[path]
+	b.inst = &instance{comp: acomp.comp, generation: acomp.generation, access: newImpl.Access}
+	orch.mu.Unlock()
+	b.mu.Unlock()
+
+	old.stop()
+	select {
+	case <-old.comp.Done():
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Stopping replaced %s: %w", path, ctx.Err())
+	}
+}
+
+// stopStarted stops the given components, started for an instance that was
+// not put in place, and forgets them.
+func (orch *Orchestrator) stopStarted(ctx context.Context, paths map[ComponentPath]bool) error {
+	errs := []error{}
+	order := orch.startOrder()
+	for i := len(order) - 1; i >= 0; i-- {
+		if paths[order[i]] {
+			if err := orch.stopComponent(ctx, order[i]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	for path := range paths {
+		delete(orch.active, path)
+		delete(orch.bindings, path)
+	}
+	return errors.Join(errs...)
+}
+
+// HandoffComponent is an optional interface for components that can hand their
+// in-memory state directly to a replacement instance (see Replace).  Unlike a
+// snapshot, this state need not be serializable; it may contain callbacks,
+// channels, and the like.  Handoff is called after the instance is drained,
+// and the instance is stopped soon after, so it may hand over values that it
+// owns.
+type HandoffComponent interface {
+	Handoff() interface{}
+}
+
+// Handoff returns the state handed off by the instance being replaced, for the
+// component with the given path, or nil if there is none.  This is only
+// non-nil while the new instance's Start function runs during Replace.
+func (orch *Orchestrator) Handoff(path ComponentPath) interface{} {
+	orch.snapshotMu.Lock()
+	defer orch.snapshotMu.Unlock()
+
+	return orch.handoff[path]
+}
+
+// drain waits until no requests to the given instance are in flight, and its
+// mailbox (if any) is empty, or the context expires.  Unless the instance's
+// binding is locked, new requests may begin as soon as this returns.
+func drain(ctx context.Context, inst *instance) error {
+	idle := make(chan struct{})
+	go func() {
+		inst.inflight.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if mc, ok := inst.comp.(MailboxComponent); ok {
+		return mc.Mailbox().waitEmpty(ctx)
+	}
+	return nil
+}
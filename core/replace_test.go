@@ -0,0 +1,305 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/clock"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// replaceFixture starts a root component depending on "svc", returning the
+// orchestrator and root's reference to svc.
+func replaceFixture(t *testing.T, svc *comptest.FakeReference) (*core.Orchestrator, core.ComponentReference) {
+	t.Helper()
+
+	var ref core.ComponentReference
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				ref = deps["svc"]
+			}),
+		fakeImpl("svc", svc, nil, nil),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+	return orch, ref
+}
+
+// handoffComponent is a fakeComponent that implements core.HandoffComponent
+// and core.Snapshotter.
+type handoffComponent struct {
+	fakeComponent
+}
+
+func (c *handoffComponent) Handoff() interface{}      { return "handed off" }
+func (c *handoffComponent) Snapshot() ([]byte, error) { return []byte("snapshot"), nil }
+
+func TestReplaceSwitchesReferences(t *testing.T) {
+	old := comptest.NewFakeReference().Respond("old", nil)
+	orch, ref := replaceFixture(t, old)
+
+	replacement := comptest.NewFakeReference().Respond("new", nil)
+	if err := orch.Replace(context.Background(), fakeImpl("svc", replacement, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if rsp, err := ref.Request(context.Background(), "ping"); rsp != "new" || err != nil {
+		t.Fatalf("got %v, %v from replacement; want new, nil", rsp, err)
+	}
+	if len(old.Messages()) != 0 {
+		t.Fatalf("old instance received %v", old.Messages())
+	}
+	if state := orch.Status()["root"].State; state != core.RunningState {
+		t.Fatalf("root is %s after replacing its dependency", state)
+	}
+}
+
+func TestReplaceHandsOverState(t *testing.T) {
+	orch := core.NewOrchestrator(core.ComponentImpl{
+		Path: "svc",
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			return &handoffComponent{fakeComponent{fake: comptest.NewFakeReference(), done: ctx.Done()}}
+		},
+	})
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	var handoff interface{}
+	var snapshot []byte
+	err := orch.Replace(context.Background(), core.ComponentImpl{
+		Path: "svc",
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			handoff, snapshot = host.Handoff(), host.Snapshot()
+			return &fakeComponent{fake: comptest.NewFakeReference(), done: ctx.Done()}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handoff != "handed off" || string(snapshot) != "snapshot" {
+		t.Fatalf("replacement started with handoff %v and snapshot %q", handoff, snapshot)
+	}
+	if orch.Handoff("svc") != nil {
+		t.Fatal("handoff outlived Replace")
+	}
+}
+
+func TestReplaceDrainsRequestsInFlight(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	old := comptest.NewFakeReference()
+	old.Handler = func(context.Context, core.Message) (core.Message, error) {
+		close(entered)
+		<-release
+		return "old", nil
+	}
+	orch, ref := replaceFixture(t, old)
+
+	inflight := make(chan core.Message, 1)
+	go func() {
+		rsp, _ := ref.Request(context.Background(), "slow")
+		inflight <- rsp
+	}()
+	<-entered
+
+	replaced := make(chan error, 1)
+	go func() {
+		replaced <- orch.Replace(context.Background(), fakeImpl("svc", comptest.NewFakeReference(), nil, nil))
+	}()
+	select {
+	case err := <-replaced:
+		t.Fatalf("Replace returned %v with a request in flight", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-replaced; err != nil {
+		t.Fatal(err)
+	}
+	if rsp := <-inflight; rsp != "old" {
+		t.Fatalf("request in flight got %v, want old", rsp)
+	}
+}
+
+func TestReplaceKeepsOldInstanceWhenStartFails(t *testing.T) {
+	old := comptest.NewFakeReference()
+	old.Handler = func(context.Context, core.Message) (core.Message, error) { return "old", nil }
+	orch, ref := replaceFixture(t, old)
+
+	err := orch.Replace(context.Background(), fakeImpl("svc", comptest.NewFakeReference(), []core.ComponentPath{"missing"}, nil))
+	if err == nil {
+		t.Fatal("Replace succeeded with a missing dependency")
+	}
+	if rsp, err := ref.Request(context.Background(), "ping"); rsp != "old" || err != nil {
+		t.Fatalf("got %v, %v after failed replace; want old, nil", rsp, err)
+	}
+
+	if err := orch.Replace(context.Background(), fakeImpl("inactive", comptest.NewFakeReference(), nil, nil)); err == nil {
+		t.Fatal("Replace succeeded for an inactive component")
+	}
+}
+
+func TestReplaceRollsBackWhenStartPanics(t *testing.T) {
+	old := comptest.NewFakeReference()
+	old.Handler = func(context.Context, core.Message) (core.Message, error) { return "old", nil }
+	orch, ref := replaceFixture(t, old)
+
+	broken := fakeImpl("svc", comptest.NewFakeReference(), nil, nil)
+	broken.Start = func(core.Host, context.Context, map[core.ComponentPath]core.ComponentReference) core.Component {
+		panic("broken")
+	}
+	err := orch.Replace(context.Background(), broken)
+	var panicErr *core.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Replace returned %v, want a *core.PanicError", err)
+	}
+
+	if rsp, err := ref.Request(context.Background(), "ping"); rsp != "old" || err != nil {
+		t.Fatalf("got %v, %v after failed replace; want old, nil", rsp, err)
+	}
+	if state := orch.Status()["svc"].State; state != core.RunningState {
+		t.Fatalf("svc is %s after failed replace", state)
+	}
+}
+
+// mailboxComponent handles messages from its mailbox, in its own goroutine,
+// with handle.
+type mailboxComponent struct {
+	mailbox *core.Mailbox
+	handle  func(context.Context, core.Message)
+	done    chan struct{}
+}
+
+func mailboxImpl(path core.ComponentPath, handle func(context.Context, core.Message)) core.ComponentImpl {
+	return core.ComponentImpl{
+		Path: path,
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			c := &mailboxComponent{
+				mailbox: core.NewMailbox(core.MailboxConfig{Capacity: 10}),
+				handle:  handle,
+				done:    make(chan struct{}),
+			}
+			go func() {
+				defer close(c.done)
+				for {
+					select {
+					case env := <-c.mailbox.C():
+						core.HandleEnvelope(env, func(ctx context.Context, msg core.Message) (core.Message, error) {
+							c.handle(ctx, msg)
+							return nil, nil
+						})
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return c
+		},
+	}
+}
+
+func (c *mailboxComponent) NewReference() core.ComponentReference { return c }
+func (c *mailboxComponent) Done() <-chan struct{}                 { return c.done }
+func (c *mailboxComponent) Mailbox() *core.Mailbox                { return c.mailbox }
+
+func (c *mailboxComponent) Request(ctx context.Context, msg core.Message) (core.Message, error) {
+	return nil, nil
+}
+
+func (c *mailboxComponent) RequestAsync(ctx context.Context, msg core.Message) {
+	if err := c.mailbox.Put(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+func TestReplaceWithFakeClock(t *testing.T) {
+	release := make(chan struct{})
+	orch := core.NewOrchestrator(mailboxImpl("svc", func(context.Context, core.Message) { <-release }))
+	orch.SetClock(clock.NewFake(comptest.FakeEpoch))
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	// one message is being handled, and another waits in the mailbox
+	orch.Root.RequestAsync(context.Background(), "first")
+	orch.Root.RequestAsync(context.Background(), "second")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), comptest.DefaultTimeout)
+	defer cancel()
+	if err := orch.Replace(ctx, mailboxImpl("svc", func(context.Context, core.Message) {})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplaceWhileCalledBack(t *testing.T) {
+	// a request in flight to svc waits for another request to svc, as when
+	// comp/users.Main sends a message through comp/conns.Main, which is
+	// itself sending to comp/users.Main
+	entered, called := make(chan struct{}), make(chan struct{})
+	old := comptest.NewFakeReference()
+	old.Handler = func(ctx context.Context, msg core.Message) (core.Message, error) {
+		if msg == "ping" {
+			close(entered)
+			<-called
+		}
+		return nil, nil
+	}
+	orch, ref := replaceFixture(t, old)
+
+	go ref.Request(context.Background(), "ping")
+	<-entered
+
+	replaced := make(chan error, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), comptest.DefaultTimeout)
+	defer cancel()
+	go func() {
+		replaced <- orch.Replace(ctx, fakeImpl("svc", comptest.NewFakeReference(), nil, nil))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := ref.Request(context.Background(), "pong"); err != nil {
+		t.Fatal(err)
+	}
+	close(called)
+	if err := <-replaced; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplaceStopsNewDependenciesOnRollback(t *testing.T) {
+	var depCtx context.Context
+	orch := core.NewOrchestrator(
+		fakeImpl("svc", comptest.NewFakeReference(), nil, nil),
+		fakeImpl("dep", comptest.NewFakeReference(), nil, func(ctx context.Context, _ map[core.ComponentPath]core.ComponentReference) {
+			depCtx = ctx
+		}),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	broken := fakeImpl("svc", comptest.NewFakeReference(), []core.ComponentPath{"dep"}, nil)
+	broken.Start = func(core.Host, context.Context, map[core.ComponentPath]core.ComponentReference) core.Component {
+		panic("broken")
+	}
+	if err := orch.Replace(context.Background(), broken); err == nil {
+		t.Fatal("Replace succeeded despite a panic")
+	}
+	if depCtx == nil || depCtx.Err() == nil {
+		t.Fatal("dep was not stopped after the failed replace")
+	}
+	if _, found := orch.Status()["dep"]; found {
+		t.Fatal("dep is still active after the failed replace")
+	}
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Idempotent is implemented by message types that can safely be delivered
+// more than once.  Only requests with such messages are retried (see
+// RetryPolicy).  The method does nothing; it is only a marker.
+type Idempotent interface {
+	Idempotent()
+}
+
+// RetryPolicy determines how a reference retries failed synchronous requests
+// with Idempotent messages.  Asynchronous requests are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// Values less than two mean requests are not retried.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry, measured with the
+	// orchestrator's clock.  It doubles for each subsequent retry.
+	Backoff time.Duration
+
+	// MaxBackoff, if not zero, limits the delay between retries.
+	MaxBackoff time.Duration
+}
+
+// BreakerPolicy configures a circuit breaker on a reference.  After Failures
+// consecutive failed requests, the breaker opens, and requests fail
+// immediately with ErrCircuitOpen.  After ResetTimeout, the breaker is
+// half-open, and allows a single trial request; if that succeeds, the breaker
+// closes, and otherwise it opens again.
+//
+// Only synchronous requests count as successes or failures, since the outcome
+// of an asynchronous request is not always known.  Requests that fail because
+// the caller's own context expired do not count.
+type BreakerPolicy struct {
+	// Failures is the number of consecutive failures that opens the breaker.
+	Failures int
+
+	// ResetTimeout is the time the breaker stays open before allowing a trial
+	// request, measured with the orchestrator's clock.
+	ResetTimeout time.Duration
+}
+
+// ErrCircuitOpen is returned for requests made through a reference whose
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("Circuit breaker is open")
+
+// BreakerState gives a circuit breaker's state.  It is one of the Breaker*
+// constants.
+type BreakerState string
+
+// BreakerState values
+const (
+	// BreakerClosed allows all requests.
+	BreakerClosed BreakerState = "closed"
+
+	// BreakerOpen fails all requests with ErrCircuitOpen.
+	BreakerOpen BreakerState = "open"
+
+	// BreakerHalfOpen allows a single trial request.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// breaker is the circuit breaker for a single reference.
+type breaker struct {
+	orch   *Orchestrator
+	caller ComponentPath
+	target ComponentPath
+	policy BreakerPolicy
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+
+	// trial is true while the half-open trial request is in progress
+	trial bool
+}
+
+func newBreaker(orch *Orchestrator, caller, target ComponentPath, policy BreakerPolicy) *breaker {
+	return &breaker{
+		orch:   orch,
+		caller: caller,
+		target: target,
+		policy: policy,
+		state:  BreakerClosed,
+	}
+}
+
+// State returns the breaker's current state.
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// allow determines whether a request may proceed, returning ErrCircuitOpen if
+// not.  If trial is true and the breaker is half-open, the request becomes
+// the trial request, and its outcome must be passed to done.
+func (b *breaker) allow(trial bool) error {
+	b.mu.Lock()
+	var events []LifecycleEvent
+	defer func() {
+		b.mu.Unlock()
+		b.publish(events)
+	}()
+
+	if b.state == BreakerOpen {
+		if b.orch.clock.Now().Sub(b.openedAt) < b.policy.ResetTimeout {
+			return ErrCircuitOpen
+		}
+		events = append(events, b.setState(BreakerHalfOpen))
+	}
+	if b.state == BreakerHalfOpen && trial {
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// done records the outcome of a synchronous request.  If counted is false,
+// the outcome says nothing about the target, and only ends any trial.
+func (b *breaker) done(err error, counted bool) {
+	b.mu.Lock()
+	var events []LifecycleEvent
+	defer func() {
+		b.mu.Unlock()
+		b.publish(events)
+	}()
+
+	b.trial = false
+	if !counted {
+		return
+	}
+	if err == nil {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			events = append(events, b.setState(BreakerClosed))
+		}
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.policy.Failures) {
+		b.openedAt = b.orch.clock.Now()
+		events = append(events, b.setState(BreakerOpen))
+	}
+}
+
+// setState changes the breaker's state, returning the event to publish.  This
+// assumes b.mu is held.
+func (b *breaker) setState(state BreakerState) LifecycleEvent {
+	b.state = state
+	return LifecycleEvent{
+		Kind:       BreakerEvent,
+		Component:  b.caller,
+		Dependency: b.target,
+		State:      string(state),
+	}
+}
+
+// publish publishes events, without holding b.mu.
+func (b *breaker) publish(events []LifecycleEvent) {
+	for _, ev := range events {
+		b.orch.lifecycleEvent(ev)
+	}
+}
+
+// retry calls attempt until it succeeds, the policy's attempts are exhausted,
+// or the context expires, waiting between attempts according to the policy.
+func (orch *Orchestrator) retry(ctx context.Context, policy RetryPolicy, attempt func() (Message, error)) (Message, error) {
+	backoff := policy.Backoff
+	for n := 1; ; n++ {
+		rsp, err := attempt()
+		if err == nil || n >= policy.MaxAttempts || errors.Is(err, ErrCircuitOpen) || ctx.Err() != nil {
+			return rsp, err
+		}
+		if backoff > 0 {
+			select {
+			case <-orch.clock.After(backoff):
+			case <-ctx.Done():
+				return rsp, err
+			}
+		}
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/clock"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// ping is an Idempotent message, so requests with it are retried.
+type ping struct{}
+
+func (ping) Idempotent() {}
+
+var errUnavailable = errors.New("unavailable")
+
+// resilienceFixture starts a root component depending on "svc", with the
+// given policies on that edge and a fake clock, returning the orchestrator,
+// the clock, and root's reference to svc.
+func resilienceFixture(t *testing.T, svc *comptest.FakeReference, retry core.RetryPolicy, breaker *core.BreakerPolicy) (*core.Orchestrator, *clock.Fake, core.ComponentReference) {
+	t.Helper()
+
+	var ref core.ComponentReference
+	root := fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+		func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+			ref = deps["svc"]
+		})
+	root.Retries = map[core.ComponentPath]core.RetryPolicy{"svc": retry}
+	if breaker != nil {
+		root.Breakers = map[core.ComponentPath]core.BreakerPolicy{"svc": *breaker}
+	}
+
+	clk := clock.NewFake(comptest.FakeEpoch)
+	orch := core.NewOrchestrator(root, fakeImpl("svc", svc, nil, nil))
+	orch.SetClock(clk)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+	return orch, clk, ref
+}
+
+func TestRetryBacksOffWithClock(t *testing.T) {
+	svc := comptest.NewFakeReference().
+		Respond(nil, errUnavailable).
+		Respond(nil, errUnavailable).
+		Respond("pong", nil)
+	_, clk, ref := resilienceFixture(t, svc, core.RetryPolicy{MaxAttempts: 3, Backoff: time.Second}, nil)
+
+	type result struct {
+		rsp core.Message
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		rsp, err := ref.Request(context.Background(), ping{})
+		done <- result{rsp, err}
+	}()
+
+	// the backoff doubles after the first retry
+	for i, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		clk.BlockUntil(1)
+		if n := len(svc.Messages()); n != i+1 {
+			t.Fatalf("%d attempts before retry %d", n, i+1)
+		}
+		clk.Advance(backoff - time.Millisecond)
+		if n := clk.Waiters(); n != 1 {
+			t.Fatalf("retry %d did not wait %s", i+1, backoff)
+		}
+		clk.Advance(time.Millisecond)
+	}
+
+	r := <-done
+	if r.rsp != "pong" || r.err != nil {
+		t.Fatalf("got %v, %v; want pong, nil", r.rsp, r.err)
+	}
+	if n := len(svc.Messages()); n != 3 {
+		t.Fatalf("%d attempts, want 3", n)
+	}
+}
+
+func TestRetryOnlyIdempotentMessages(t *testing.T) {
+	svc := comptest.NewFakeReference().Respond(nil, errUnavailable)
+	_, _, ref := resilienceFixture(t, svc, core.RetryPolicy{MaxAttempts: 3}, nil)
+
+	if _, err := ref.Request(context.Background(), "not idempotent"); !errors.Is(err, errUnavailable) {
+		t.Fatalf("got %v, want errUnavailable", err)
+	}
+	if n := len(svc.Messages()); n != 1 {
+		t.Fatalf("%d attempts, want 1", n)
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	svc := comptest.NewFakeReference().
+		Respond(nil, errUnavailable).
+		Respond(nil, errUnavailable).
+		Respond(nil, errUnavailable).
+		Respond("pong", nil)
+	policy := core.BreakerPolicy{Failures: 2, ResetTimeout: time.Minute}
+	orch, clk, ref := resilienceFixture(t, svc, core.RetryPolicy{}, &policy)
+	state := func() core.BreakerState { return orch.Status()["root"].Breakers["svc"] }
+	request := func() error {
+		_, err := ref.Request(context.Background(), "ping")
+		return err
+	}
+
+	request()
+	if s := state(); s != core.BreakerClosed {
+		t.Fatalf("breaker %s after one failure, want closed", s)
+	}
+	request()
+	if s := state(); s != core.BreakerOpen {
+		t.Fatalf("breaker %s after two failures, want open", s)
+	}
+	if err := request(); !errors.Is(err, core.ErrCircuitOpen) {
+		t.Fatalf("request through open breaker returned %v", err)
+	}
+	if n := len(svc.Messages()); n != 2 {
+		t.Fatalf("svc received %d requests, want 2", n)
+	}
+
+	// a failed trial opens the breaker again, for another ResetTimeout
+	clk.Advance(time.Minute)
+	if err := request(); !errors.Is(err, errUnavailable) {
+		t.Fatalf("trial request returned %v", err)
+	}
+	if s := state(); s != core.BreakerOpen {
+		t.Fatalf("breaker %s after failed trial, want open", s)
+	}
+	clk.Advance(time.Minute - time.Millisecond)
+	if err := request(); !errors.Is(err, core.ErrCircuitOpen) {
+		t.Fatalf("request before ResetTimeout returned %v", err)
+	}
+
+	clk.Advance(time.Millisecond)
+	if err := request(); err != nil {
+		t.Fatalf("trial request returned %v", err)
+	}
+	if s := state(); s != core.BreakerClosed {
+		t.Fatalf("breaker %s after successful trial, want closed", s)
+	}
+}
+
+func TestBreakerLifecycleEvents(t *testing.T) {
+	svc := comptest.NewFakeReference().Respond(nil, errUnavailable)
+	policy := core.BreakerPolicy{Failures: 1, ResetTimeout: time.Minute}
+	orch, clk, ref := resilienceFixture(t, svc, core.RetryPolicy{}, &policy)
+	sink := &eventSink{events: make(chan core.LifecycleEvent, 3)}
+	orch.HandleLifecycleEvents(sink)
+
+	ref.Request(context.Background(), "fail")
+	clk.Advance(time.Minute)
+	ref.Request(context.Background(), "succeed")
+
+	for i, state := range []core.BreakerState{core.BreakerOpen, core.BreakerHalfOpen, core.BreakerClosed} {
+		ev := <-sink.events
+		want := core.LifecycleEvent{
+			Kind:       core.BreakerEvent,
+			Component:  "root",
+			Dependency: "svc",
+			State:      string(state),
+			Time:       comptest.FakeEpoch,
+		}
+		if i > 0 {
+			want.Time = comptest.FakeEpoch.Add(time.Minute)
+		}
+		if ev != want {
+			t.Errorf("event %d: %+v, want %+v", i, ev, want)
+		}
+	}
+	if topic := (core.LifecycleEvent{Kind: core.BreakerEvent}).Topic(); topic != "lifecycle.breaker" {
+		t.Errorf("topic %q", topic)
+	}
+}
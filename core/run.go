@@ -0,0 +1,167 @@
+package core
+
+import (
+	"comps/core/clock"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// Exit statuses returned from Orchestrator#Run
+const (
+	// ExitSuccess indicates that the orchestrator started and stopped cleanly.
+	ExitSuccess = 0
+
+	// ExitFailure indicates that the orchestrator failed to start or stop,
+	// or that a component requested shutdown due to an error.
+	ExitFailure = 1
+
+	// ExitForced is the status with which the process exits when a second
+	// signal arrives during shutdown.
+	ExitForced = 2
+)
+
+// DefaultStopTimeout is the default time Run allows for components to stop.
+const DefaultStopTimeout = 30 * time.Second
+
+// leakGracePeriod is the time Run allows, after stopping, for components'
+// goroutines to exit before reporting them as leaked.
+const leakGracePeriod = 100 * time.Millisecond
+
+// SetStopTimeout sets the time Run allows for components to stop, measured
+// with the orchestrator's clock.  This must be called before Run.
+func (orch *Orchestrator) SetStopTimeout(timeout time.Duration) {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	orch.stopTimeout = timeout
+}
+
+// RequestShutdown asks the orchestrator to shut down, causing Run to return.
+// A nil error indicates a normal shutdown, while a non-nil error indicates a
+// failure and causes Run to return ExitFailure.  Only the first request has
+// any effect, and this method never blocks.
+func (orch *Orchestrator) RequestShutdown(err error) {
+	select {
+	case orch.shutdown <- err:
+	default:
+	}
+}
+
+// Run starts the orchestrator and blocks until it receives SIGINT or SIGTERM,
+// a component calls RequestShutdown, or the context is cancelled.  It then
+// stops the orchestrator, allowing the stop timeout (see SetStopTimeout) for
+// components to finish.  A second signal during shutdown exits the process
+// immediately with ExitForced.
+//
+// Each SIGHUP received while running triggers Reconfigure, and the outcome of
+// each step is reported on stderr.
+//
+// The return value is an exit status suitable for os.Exit.
+func (orch *Orchestrator) Run(ctx context.Context) int {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	hups := make(chan os.Signal, 1)
+	signal.Notify(hups, syscall.SIGHUP)
+	defer signal.Stop(hups)
+
+	orch.mu.Lock()
+	stopTimeout := orch.stopTimeout
+	orch.mu.Unlock()
+
+	if err := orch.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting: %s\n", err)
+		// stop any components that started before the failure
+		stopCtx, cancel := clock.WithTimeout(context.Background(), orch.clock, stopTimeout)
+		defer cancel()
+		if err := orch.Stop(stopCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error stopping: %s\n", err)
+		}
+		return ExitFailure
+	}
+
+	status := ExitSuccess
+wait:
+	for {
+		select {
+		case <-hups:
+			fmt.Fprintf(os.Stderr, "Received SIGHUP; reconfiguring\n")
+			reconfigCtx, cancel := clock.WithTimeout(context.Background(), orch.clock, stopTimeout)
+			steps, err := orch.Reconfigure(reconfigCtx)
+			cancel()
+			for _, step := range steps {
+				fmt.Fprintf(os.Stderr, "  %s\n", step)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Reconfiguration failed: %s\n", err)
+			}
+		case sig := <-sigs:
+			fmt.Fprintf(os.Stderr, "Received %s; shutting down (repeat to force exit)\n", sig)
+			break wait
+		case err := <-orch.shutdown:
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Shutting down due to error: %s\n", err)
+				status = ExitFailure
+			}
+			break wait
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case sig := <-sigs:
+			fmt.Fprintf(os.Stderr, "Received %s again; exiting immediately\n", sig)
+			os.Exit(ExitForced)
+		case <-stopped:
+		}
+	}()
+
+	stopCtx, cancel := clock.WithTimeout(context.Background(), orch.clock, stopTimeout)
+	defer cancel()
+	if err := orch.Stop(stopCtx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error stopping: %s\n", err)
+		status = ExitFailure
+	}
+
+	orch.reportLeaks()
+	return status
+}
+
+// reportLeaks reports, on stderr, any components with goroutines still
+// running after they have stopped.  Goroutines often exit just after a
+// component's Done channel closes, so this allows a short grace period.
+func (orch *Orchestrator) reportLeaks() {
+	deadline := orch.clock.After(leakGracePeriod)
+	leaks := orch.LeakedGoroutines()
+wait:
+	for len(leaks) > 0 {
+		select {
+		case <-orch.clock.After(leakGracePeriod / 10):
+			leaks = orch.LeakedGoroutines()
+		case <-deadline:
+			break wait
+		}
+	}
+	if len(leaks) == 0 {
+		return
+	}
+
+	paths := make([]ComponentPath, 0, len(leaks))
+	for path := range leaks {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	fmt.Fprintf(os.Stderr, "Goroutines still running after stop:\n")
+	for _, path := range paths {
+		fmt.Fprintf(os.Stderr, "  %s: %d\n", path, leaks[path])
+	}
+}
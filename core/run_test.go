@@ -0,0 +1,130 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// runAsync runs the orchestrator in a goroutine, returning a channel that
+// carries its exit status.
+func runAsync(ctx context.Context, orch *core.Orchestrator) <-chan int {
+	status := make(chan int, 1)
+	go func() { status <- orch.Run(ctx) }()
+	return status
+}
+
+// exitStatus waits for Run to return, failing the test if it does not.
+func exitStatus(t *testing.T, status <-chan int) int {
+	t.Helper()
+
+	select {
+	case s := <-status:
+		return s
+	case <-time.After(comptest.DefaultTimeout):
+		t.Fatal("Run did not return")
+		return -1
+	}
+}
+
+// runnable returns an orchestrator whose root component closes started once
+// it has started.
+func runnable(started chan struct{}) *core.Orchestrator {
+	return core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"dep"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				close(started)
+			}),
+		fakeImpl("dep", comptest.NewFakeReference(), nil, nil),
+	)
+}
+
+func TestRunUntilShutdownRequested(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{{nil, core.ExitSuccess}, {errors.New("broken"), core.ExitFailure}} {
+		started := make(chan struct{})
+		orch := runnable(started)
+		status := runAsync(context.Background(), orch)
+		<-started
+
+		orch.RequestShutdown(tc.err)
+		if got := exitStatus(t, status); got != tc.want {
+			t.Errorf("RequestShutdown(%v): exit status %d, want %d", tc.err, got, tc.want)
+		}
+		for path, s := range orch.Status() {
+			if s.State != core.StoppedState {
+				t.Errorf("%s is %s after Run returned", path, s.State)
+			}
+		}
+	}
+}
+
+func TestRunUntilContextCancelled(t *testing.T) {
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	status := runAsync(ctx, runnable(started))
+	<-started
+
+	cancel()
+	if got := exitStatus(t, status); got != core.ExitSuccess {
+		t.Fatalf("exit status %d, want %d", got, core.ExitSuccess)
+	}
+}
+
+func TestRunUntilSignal(t *testing.T) {
+	started := make(chan struct{})
+	status := runAsync(context.Background(), runnable(started))
+	<-started
+
+	// Run is handling SIGINT by the time the root component starts
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+	if got := exitStatus(t, status); got != core.ExitSuccess {
+		t.Fatalf("exit status %d, want %d", got, core.ExitSuccess)
+	}
+}
+
+func TestRunFailsToStart(t *testing.T) {
+	var depCtx context.Context
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"dep", "missing"}, nil),
+		fakeImpl("dep", comptest.NewFakeReference(), nil, func(ctx context.Context, _ map[core.ComponentPath]core.ComponentReference) {
+			depCtx = ctx
+		}),
+	)
+	if got := exitStatus(t, runAsync(context.Background(), orch)); got != core.ExitFailure {
+		t.Fatalf("exit status %d, want %d", got, core.ExitFailure)
+	}
+	if depCtx == nil {
+		t.Fatal("dep was not started")
+	}
+	if depCtx.Err() == nil {
+		t.Fatal("dep was not stopped after the failed start")
+	}
+}
+
+func TestRunStopTimeout(t *testing.T) {
+	started := make(chan struct{})
+	orch := core.NewOrchestrator(core.ComponentImpl{
+		Path: "stuck",
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			close(started)
+			// never done
+			return &fakeComponent{fake: comptest.NewFakeReference()}
+		},
+	})
+	orch.SetStopTimeout(10 * time.Millisecond)
+	status := runAsync(context.Background(), orch)
+	<-started
+
+	orch.RequestShutdown(nil)
+	if got := exitStatus(t, status); got != core.ExitFailure {
+		t.Fatalf("exit status %d, want %d", got, core.ExitFailure)
+	}
+}
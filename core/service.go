@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Service names a role that components can provide and require, such as
+// "logger", so that dependents need not name a specific implementation.  A
+// component lists the services it provides in ComponentImpl#Provides, and
+// those it needs in ComponentImpl#Requires or ComponentImpl#RequiresAll.
+type Service string
+
+// SetProvider selects the component that provides the given service to
+// components that require it, when more than one registered component
+// provides it.  This must be called before Start.
+func (orch *Orchestrator) SetProvider(service Service, path ComponentPath) {
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+
+	orch.providers[service] = path
+}
+
+// provides determines whether the registered component with the given path
+// provides the given service.
+func (orch *Orchestrator) provides(path ComponentPath, service Service) bool {
+	for _, s := range orch.registered[path].Provides {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// allProviders returns the paths of the registered components, other than
+// exclude, that provide the given service, in order.  This assumes that
+// orch.mu is held.
+func (orch *Orchestrator) allProviders(service Service, exclude ComponentPath) []ComponentPath {
+	paths := []ComponentPath{}
+	for path := range orch.registered {
+		if path != exclude && orch.provides(path, service) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	return paths
+}
+
+// provider returns the path of the component that provides the given service
+// to the component with path caller: the one selected with SetProvider, or
+// else the only registered provider.  This assumes that orch.mu is held.
+func (orch *Orchestrator) provider(service Service, caller ComponentPath) (ComponentPath, error) {
+	if path, found := orch.providers[service]; found {
+		if !orch.provides(path, service) {
+			return "", fmt.Errorf("Component %s, selected to provide %s, does not provide it", path, service)
+		}
+		return path, nil
+	}
+	paths := orch.allProviders(service, caller)
+	switch len(paths) {
+	case 0:
+		return "", fmt.Errorf("No component provides %s, required by %s", service, caller)
+	case 1:
+		return paths[0], nil
+	default:
+		names := []string{}
+		for _, p := range paths {
+			names = append(names, string(p))
+		}
+		return "", fmt.Errorf("Several components provide %s, required by %s (%s); select one with SetProvider",
+			service, caller, strings.Join(names, ", "))
+	}
+}
+
+// serviceProviders resolves the services required by the registered
+// component with the given path, returning the paths of their providers,
+// keyed by service.  This assumes that orch.mu is held.
+func (orch *Orchestrator) serviceProviders(path ComponentPath) (map[Service][]ComponentPath, error) {
+	compImpl := orch.registered[path]
+	rv := map[Service][]ComponentPath{}
+	for _, service := range compImpl.Requires {
+		provider, err := orch.provider(service, path)
+		if err != nil {
+			return nil, err
+		}
+		rv[service] = []ComponentPath{provider}
+	}
+	for _, service := range compImpl.RequiresAll {
+		rv[service] = orch.allProviders(service, path)
+	}
+	return rv, nil
+}
@@ -0,0 +1,139 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// providerImpl returns an implementation of a fakeComponent providing the
+// given service.
+func providerImpl(path core.ComponentPath, fake *comptest.FakeReference, service core.Service) core.ComponentImpl {
+	impl := fakeImpl(path, fake, nil, nil)
+	impl.Provides = []core.Service{service}
+	return impl
+}
+
+// requirerImpl returns an implementation, with path "app", that requires the
+// logger service (or all of its providers, if all is true), and passes its
+// Host to use.
+func requirerImpl(all bool, use func(core.Host)) core.ComponentImpl {
+	impl := hostImpl("app", nil, use)
+	if all {
+		impl.RequiresAll = []core.Service{"logger"}
+	} else {
+		impl.Requires = []core.Service{"logger"}
+	}
+	return impl
+}
+
+func TestRequiresResolvesProvider(t *testing.T) {
+	logger := comptest.NewFakeReference()
+	var ref core.ComponentReference
+	orch := core.NewOrchestrator(
+		requirerImpl(false, func(host core.Host) { ref = host.Service("logger") }),
+		providerImpl("stderr", logger, "logger"),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	ref.Request(context.Background(), "hello")
+	if caller, _ := core.Caller(logger.Received()[0].Ctx); caller != "app" {
+		t.Errorf("request to provider from %q, want app", caller)
+	}
+	want := core.GraphEdge{From: "app", To: "stderr", Service: "logger"}
+	if edges := orch.Graph().Edges; len(edges) != 1 || edges[0] != want {
+		t.Errorf("edges %v, want [%v]", edges, want)
+	}
+}
+
+func TestProviderTimeoutByPath(t *testing.T) {
+	logger := comptest.NewFakeReference()
+	logger.Handler = func(ctx context.Context, msg core.Message) (core.Message, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	var ref core.ComponentReference
+	app := requirerImpl(false, func(host core.Host) { ref = host.Service("logger") })
+	app.Timeouts = map[core.ComponentPath]time.Duration{"stderr": time.Millisecond}
+	orch := core.NewOrchestrator(app, providerImpl("stderr", logger, "logger"))
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	if _, err := ref.Request(context.Background(), "hello"); err != context.DeadlineExceeded {
+		t.Fatalf("request to provider returned %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestSetProvider(t *testing.T) {
+	stderr, file := comptest.NewFakeReference(), comptest.NewFakeReference()
+	newOrch := func(ref *core.ComponentReference) *core.Orchestrator {
+		return core.NewOrchestrator(
+			requirerImpl(false, func(host core.Host) { *ref = host.Service("logger") }),
+			providerImpl("stderr", stderr, "logger"),
+			providerImpl("file", file, "logger"),
+			fakeImpl("other", comptest.NewFakeReference(), nil, nil),
+		)
+	}
+
+	var ref core.ComponentReference
+	if err := newOrch(&ref).Start(); err == nil || !strings.Contains(err.Error(), "Several components provide logger") {
+		t.Errorf("Start with two providers returned %v", err)
+	}
+
+	orch := newOrch(&ref)
+	orch.SetProvider("logger", "other")
+	if err := orch.Start(); err == nil || !strings.Contains(err.Error(), "does not provide it") {
+		t.Errorf("Start with a non-provider selected returned %v", err)
+	}
+
+	orch = newOrch(&ref)
+	orch.SetProvider("logger", "file")
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+	ref.Request(context.Background(), "hello")
+	if len(file.Messages()) != 1 || len(stderr.Messages()) != 0 {
+		t.Errorf("file received %v and stderr %v", file.Messages(), stderr.Messages())
+	}
+	if _, found := orch.Status()["stderr"]; found {
+		t.Error("unselected provider was started")
+	}
+}
+
+func TestRequiresWithoutProvider(t *testing.T) {
+	orch := core.NewOrchestrator(requirerImpl(false, func(core.Host) {}))
+	if err := orch.Start(); err == nil || !strings.Contains(err.Error(), "No component provides logger") {
+		t.Fatalf("Start returned %v", err)
+	}
+}
+
+func TestRequiresAll(t *testing.T) {
+	stderr, file := comptest.NewFakeReference(), comptest.NewFakeReference()
+	var refs []core.ComponentReference
+	orch := core.NewOrchestrator(
+		requirerImpl(true, func(host core.Host) { refs = host.Services("logger") }),
+		providerImpl("stderr", stderr, "logger"),
+		providerImpl("file", file, "logger"),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	if len(refs) != 2 {
+		t.Fatalf("%d providers, want 2", len(refs))
+	}
+	refs[0].Request(context.Background(), "to file")
+	refs[1].Request(context.Background(), "to stderr")
+	comptest.AssertMessages(t, file, "to file")
+	comptest.AssertMessages(t, stderr, "to stderr")
+}
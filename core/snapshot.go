@@ -0,0 +1,148 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshotter is an optional interface for components with in-memory state
+// that should survive a restart.  The orchestrator calls Snapshot just before
+// stopping the component, and hands the result to the next instance of the
+// same component, which retrieves it with Host#Snapshot in its Start
+// function.
+//
+// Snapshot may be called concurrently with requests to the component, and any
+// changes made after it returns are lost.
+type Snapshotter interface {
+	Snapshot() ([]byte, error)
+}
+
+// SnapshotStore persists snapshots, so that they survive a process restart.
+type SnapshotStore interface {
+	// Load returns all stored snapshots, keyed by component path.
+	Load() (map[ComponentPath][]byte, error)
+
+	// Save stores the snapshot for a single component, replacing any
+	// existing snapshot.
+	Save(path ComponentPath, data []byte) error
+}
+
+// snapshotSuffix is the filename suffix for snapshots in a DirSnapshotStore
+const snapshotSuffix = ".snapshot"
+
+// DirSnapshotStore returns a SnapshotStore that keeps one file per component
+// in the given directory, which is created if necessary.
+func DirSnapshotStore(dir string) SnapshotStore {
+	return dirSnapshotStore(dir)
+}
+
+type dirSnapshotStore string
+
+// Load implements SnapshotStore#Load.
+func (dir dirSnapshotStore) Load() (map[ComponentPath][]byte, error) {
+	snapshots := map[ComponentPath][]byte{}
+	entries, err := ioutil.ReadDir(string(dir))
+	if os.IsNotExist(err) {
+		return snapshots, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		path, err := url.PathUnescape(strings.TrimSuffix(name, snapshotSuffix))
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(string(dir), name))
+		if err != nil {
+			return nil, err
+		}
+		snapshots[ComponentPath(path)] = data
+	}
+	return snapshots, nil
+}
+
+// Save implements SnapshotStore#Save.
+func (dir dirSnapshotStore) Save(path ComponentPath, data []byte) error {
+	if err := os.MkdirAll(string(dir), 0755); err != nil {
+		return err
+	}
+	filename := filepath.Join(string(dir), url.PathEscape(string(path))+snapshotSuffix)
+	// write to a temporary file and rename it, so that a crash never leaves a
+	// partial snapshot behind
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// SetSnapshotStore sets the store in which snapshots are persisted.  This must
+// be called before Start, which loads any stored snapshots.  Without a store,
+// snapshots are kept in memory only, and survive component restarts but not
+// process restarts.
+func (orch *Orchestrator) SetSnapshotStore(store SnapshotStore) {
+	orch.snapshotMu.Lock()
+	defer orch.snapshotMu.Unlock()
+
+	orch.snapshotStore = store
+}
+
+// Snapshot returns the most recent snapshot of the component with the given
+// path, or nil if there is none.  Components implementing Snapshotter
+// typically read their own with Host#Snapshot, from their Start function, to
+// restore their state.
+func (orch *Orchestrator) Snapshot(path ComponentPath) []byte {
+	orch.snapshotMu.Lock()
+	defer orch.snapshotMu.Unlock()
+
+	return orch.snapshots[path]
+}
+
+// loadSnapshots loads the stored snapshots, if there is a snapshot store.
+func (orch *Orchestrator) loadSnapshots() error {
+	orch.snapshotMu.Lock()
+	defer orch.snapshotMu.Unlock()
+
+	if orch.snapshotStore == nil {
+		return nil
+	}
+	snapshots, err := orch.snapshotStore.Load()
+	if err != nil {
+		return fmt.Errorf("Loading snapshots: %w", err)
+	}
+	orch.snapshots = snapshots
+	return nil
+}
+
+// snapshot takes a snapshot of the given component, if it implements
+// Snapshotter, and saves it.
+func (orch *Orchestrator) snapshot(path ComponentPath, comp Component) error {
+	snapshotter, ok := comp.(Snapshotter)
+	if !ok {
+		return nil
+	}
+	data, err := snapshotter.Snapshot()
+	if err != nil {
+		return fmt.Errorf("Snapshotting %s: %w", path, err)
+	}
+
+	orch.snapshotMu.Lock()
+	defer orch.snapshotMu.Unlock()
+
+	orch.snapshots[path] = data
+	if orch.snapshotStore != nil {
+		if err := orch.snapshotStore.Save(path, data); err != nil {
+			return fmt.Errorf("Saving snapshot of %s: %w", path, err)
+		}
+	}
+	return nil
+}
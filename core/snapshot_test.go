@@ -0,0 +1,114 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// snapshottingComponent is a fakeComponent that implements core.Snapshotter.
+type snapshottingComponent struct {
+	fakeComponent
+	snapshot []byte
+	err      error
+}
+
+func (c *snapshottingComponent) Snapshot() ([]byte, error) { return c.snapshot, c.err }
+
+// snapshottingImpl returns an implementation of a snapshottingComponent that
+// takes the given snapshot, and which sends the snapshot it was started with
+// to restored.
+func snapshottingImpl(path core.ComponentPath, deps []core.ComponentPath, snapshot []byte, err error, restored chan<- []byte) core.ComponentImpl {
+	return core.ComponentImpl{
+		Path:         path,
+		Dependencies: deps,
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			if restored != nil {
+				restored <- host.Snapshot()
+			}
+			return &snapshottingComponent{
+				fakeComponent: fakeComponent{fake: comptest.NewFakeReference(), done: ctx.Done()},
+				snapshot:      snapshot,
+				err:           err,
+			}
+		},
+	}
+}
+
+func TestDirSnapshotStore(t *testing.T) {
+	store := core.DirSnapshotStore(filepath.Join(t.TempDir(), "snapshots"))
+
+	loaded, err := store.Load()
+	if err != nil || len(loaded) != 0 {
+		t.Fatalf("Load from a missing directory returned %v, %v", loaded, err)
+	}
+
+	want := map[core.ComponentPath][]byte{
+		"comp/users.Main": []byte(`{"rooms": 1}`),
+		"a/b?c d":         []byte("escaped"),
+	}
+	for path, data := range want {
+		if err := store.Save(path, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Save("comp/users.Main", []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	want["comp/users.Main"] = []byte("replaced")
+
+	loaded, err = store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, want) {
+		t.Fatalf("loaded %q, want %q", loaded, want)
+	}
+}
+
+func TestSnapshotsSurviveProcessRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	orch := core.NewOrchestrator(snapshottingImpl("counter", nil, []byte("42"), nil, nil))
+	orch.SetSnapshotStore(core.DirSnapshotStore(dir))
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	stop(t, orch)
+
+	restored := make(chan []byte, 1)
+	orch = core.NewOrchestrator(snapshottingImpl("counter", nil, nil, nil, restored))
+	orch.SetSnapshotStore(core.DirSnapshotStore(dir))
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+	if got := string(<-restored); got != "42" {
+		t.Fatalf("restored %q, want %q", got, "42")
+	}
+}
+
+func TestSnapshotFailureDoesNotPreventStop(t *testing.T) {
+	orch := core.NewOrchestrator(
+		snapshottingImpl("root", []core.ComponentPath{"dep"}, nil, errors.New("unserializable"), nil),
+		fakeImpl("dep", comptest.NewFakeReference(), nil, nil),
+	)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), comptest.DefaultTimeout)
+	defer cancel()
+	if err := orch.Stop(ctx); err == nil {
+		t.Error("Stop did not report the snapshot failure")
+	}
+	for path, s := range orch.Status() {
+		if s.State != core.StoppedState {
+			t.Errorf("%s is %s after Stop", path, s.State)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/clock"
+	"comps/core/comptest"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// statsComponent is a fakeComponent that implements core.StatsComponent.
+type statsComponent struct {
+	fakeComponent
+}
+
+func (c *statsComponent) Stats() map[string]interface{} {
+	return map[string]interface{}{"items": len(c.fake.Messages())}
+}
+
+func TestStatus(t *testing.T) {
+	clk := clock.NewFake(comptest.FakeEpoch)
+	broken := errors.New("broken")
+	svc := comptest.NewFakeReference()
+	svc.Handler = func(context.Context, core.Message) (core.Message, error) { return nil, broken }
+
+	var ref core.ComponentReference
+	svcImpl := core.ComponentImpl{
+		Path: "svc",
+		Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+			return &statsComponent{fakeComponent{fake: svc, done: ctx.Done()}}
+		},
+	}
+	orch := core.NewOrchestrator(
+		fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+			func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+				ref = deps["svc"]
+			}),
+		svcImpl,
+	)
+	orch.SetClock(clk)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, orch)
+
+	clk.Advance(time.Minute)
+	if _, err := ref.Request(context.Background(), "ping"); err != broken {
+		t.Fatalf("request returned %v", err)
+	}
+
+	status := orch.Status()["svc"]
+	if !status.StartTime.Equal(comptest.FakeEpoch) || status.Uptime != time.Minute {
+		t.Errorf("started at %s, up %s", status.StartTime, status.Uptime)
+	}
+	if status.Restarts != 0 {
+		t.Errorf("%d restarts before any restart", status.Restarts)
+	}
+	if status.LastError != broken || !status.LastErrorTime.Equal(comptest.FakeEpoch.Add(time.Minute)) {
+		t.Errorf("last error %v at %s", status.LastError, status.LastErrorTime)
+	}
+	if !reflect.DeepEqual(status.Dependents, []core.ComponentPath{"root"}) {
+		t.Errorf("dependents %v, want [root]", status.Dependents)
+	}
+	if !reflect.DeepEqual(status.Stats, map[string]interface{}{"items": 1}) {
+		t.Errorf("stats %v", status.Stats)
+	}
+	if root := orch.Status()["root"]; root.Stats != nil || len(root.Dependents) != 0 {
+		t.Errorf("root has stats %v and dependents %v", root.Stats, root.Dependents)
+	}
+
+	clk.Advance(time.Minute)
+	if err := orch.Replace(context.Background(), svcImpl); err != nil {
+		t.Fatal(err)
+	}
+	status = orch.Status()["svc"]
+	if status.Restarts != 1 || status.Uptime != 0 || status.LastError != broken {
+		t.Errorf("after restart: %d restarts, up %s, last error %v", status.Restarts, status.Uptime, status.LastError)
+	}
+}
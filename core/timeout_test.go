@@ -0,0 +1,72 @@
+package core_test
+
+import (
+	"comps/core"
+	"comps/core/clock"
+	"comps/core/comptest"
+	"context"
+	"testing"
+	"time"
+)
+
+// startWithTimeout starts root, which depends on svc with the given default
+// timeout.  svc blocks each request until its context is done.  It returns
+// the orchestrator, root's reference to svc, and svc's fake.
+func startWithTimeout(t *testing.T, clk clock.Clock, timeout time.Duration) (*core.Orchestrator, core.ComponentReference, *comptest.FakeReference) {
+	t.Helper()
+
+	svc := comptest.NewFakeReference()
+	svc.Handler = func(ctx context.Context, msg core.Message) (core.Message, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	var ref core.ComponentReference
+	root := fakeImpl("root", comptest.NewFakeReference(), []core.ComponentPath{"svc"},
+		func(ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) {
+			ref = deps["svc"]
+		})
+	root.Timeouts = map[core.ComponentPath]time.Duration{"svc": timeout}
+	orch := core.NewOrchestrator(root, fakeImpl("svc", svc, nil, nil))
+	orch.SetClock(clk)
+	if err := orch.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stop(t, orch) })
+	return orch, ref, svc
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	clk := clock.NewFake(comptest.FakeEpoch)
+	orch, ref, _ := startWithTimeout(t, clk, time.Second)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := ref.Request(context.Background(), "ping")
+		result <- err
+	}()
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	if err := <-result; err != context.DeadlineExceeded {
+		t.Fatalf("request returned %v, want DeadlineExceeded", err)
+	}
+	if n := orch.Status()["svc"].Timeouts; n != 1 {
+		t.Fatalf("%d timeouts recorded, want 1", n)
+	}
+}
+
+func TestDefaultTimeoutKeepsCallerDeadline(t *testing.T) {
+	orch, ref, svc := startWithTimeout(t, clock.Real(), time.Hour)
+
+	deadline := time.Now().Add(10 * time.Millisecond)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if _, err := ref.Request(ctx, "ping"); err != context.DeadlineExceeded {
+		t.Fatalf("request returned %v, want DeadlineExceeded", err)
+	}
+	if got, _ := svc.Received()[0].Ctx.Deadline(); !got.Equal(deadline) {
+		t.Fatalf("request deadline %s, want the caller's %s", got, deadline)
+	}
+	if n := orch.Status()["svc"].Timeouts; n != 0 {
+		t.Fatalf("caller's own deadline counted as %d timeouts", n)
+	}
+}
@@ -1,6 +1,9 @@
 package core
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // ComponentPath identifies a component.
 //
@@ -20,6 +23,10 @@ const (
 
 	// Stopped identifies a component that is stopped
 	StoppedState ComponentState = "stopped"
+
+	// FailedState identifies a component that has panicked, and has not (yet)
+	// been restarted
+	FailedState ComponentState = "failed"
 )
 
 // ComponentImpl defines a component implementation.  These are simple (usually
@@ -39,8 +46,57 @@ type ComponentImpl struct {
 	// the component has fully stopped.
 	//
 	// The `deps` map will contain an entry for every dependency path given by
-	// Dependencies.
-	Start func(*Orchestrator, context.Context, map[ComponentPath]ComponentReference) Component
+	// Dependencies.  The Host has the capabilities given by Capabilities.
+	Start func(Host, context.Context, map[ComponentPath]ComponentReference) Component
+
+	// CheckConfig, if set, checks a configuration for the component without
+	// applying it, returning an error if the component would reject it.
+	// Orchestrator#Plan reports these errors.
+	CheckConfig func(Config) error
+
+	// Capabilities lists the powers over the orchestrator that the component
+	// needs, beyond its dependencies.  By default, it has none.
+	Capabilities []Capability
+
+	// Provides lists the services this component provides to components that
+	// require them.
+	Provides []Service
+
+	// Requires lists services on which this component relies, each from a
+	// single provider (see Orchestrator#SetProvider).  The Start function
+	// gets a reference to each provider with Host#Service.  Per-dependency
+	// policies, such as Timeouts, apply to providers by their paths.
+	Requires []Service
+
+	// RequiresAll lists services on which this component relies from every
+	// registered provider (other than itself), if any.  The Start function
+	// gets the references with Host#Services.  A service may not appear in
+	// both Requires and RequiresAll.
+	RequiresAll []Service
+
+	// Restart is the policy applied when the component fails.  By default,
+	// failed components are not restarted.
+	Restart RestartPolicy
+
+	// Timeouts gives default timeouts for requests to some or all of the
+	// component's dependencies, keyed by dependency path.  A timeout applies
+	// to requests (synchronous or asynchronous) whose context has no
+	// deadline, and is measured with the orchestrator's clock.  For an
+	// asynchronous request, it starts when the request is sent, so it
+	// includes any time the message waits in the target's mailbox.
+	Timeouts map[ComponentPath]time.Duration
+
+	// Retries gives policies for retrying failed requests to some or all of
+	// the component's dependencies, keyed by dependency path.
+	Retries map[ComponentPath]RetryPolicy
+
+	// Breakers gives circuit breaker policies for requests to some or all of
+	// the component's dependencies, keyed by dependency path.
+	Breakers map[ComponentPath]BreakerPolicy
+
+	// Access restricts the messages other components may send to this one.
+	// By default, any message is allowed.
+	Access AccessPolicy
 }
 
 // Component represents a running instance of a component implementation.
@@ -74,6 +130,70 @@ type ComponentStatus struct {
 	// Dependencies gives the component's dependencies.
 	Dependencies []ComponentPath
 
+	// Dependents gives the active components that depend on this component.
+	Dependents []ComponentPath
+
 	// State gives the component's current state.
 	State ComponentState
+
+	// Overridden is true if the registered implementation has been replaced
+	// with Orchestrator#Override.
+	Overridden bool
+
+	// OverriddenBy is the original Path of the overriding implementation,
+	// which may be empty, or the same as the component's path.
+	OverriddenBy ComponentPath
+
+	// StartTime is the time at which the current instance was started.
+	StartTime time.Time
+
+	// Uptime is the time since StartTime.
+	Uptime time.Duration
+
+	// StartDuration is the time the current instance's Start function took.
+	StartDuration time.Duration
+
+	// Restarts counts the times the component has been started again after
+	// its first start, by reconfiguration or replacement.
+	Restarts int
+
+	// Failures counts the times the component has failed, by panicking.
+	Failures int
+
+	// Timeouts counts the requests to the component that failed because a
+	// default timeout (see ComponentImpl#Timeouts) expired.
+	Timeouts int
+
+	// Denied counts the requests to the component rejected by its
+	// AccessPolicy.
+	Denied int
+
+	// LastError is the most recent error returned from a request to the
+	// component by another component (including asynchronous failures), or
+	// nil if there has been none.  If the component panicked, this is a
+	// *PanicError, including the stack.
+	LastError error
+
+	// LastErrorTime is the time at which LastError occurred.
+	LastErrorTime time.Time
+
+	// Breakers gives the state of the circuit breakers on the component's
+	// references to its dependencies, keyed by dependency, or is nil if there
+	// are none.
+	Breakers map[ComponentPath]BreakerState
+
+	// Mailbox gives the stats for the component's mailbox, if it implements
+	// MailboxComponent, and is nil otherwise.
+	Mailbox *MailboxStats
+
+	// Stats gives the component's own stats, if it implements
+	// StatsComponent, and is nil otherwise.
+	Stats map[string]interface{}
+}
+
+// StatsComponent is an optional interface for components that report their
+// own stats, such as the number of items they manage, for inclusion in their
+// ComponentStatus.  Stats should be quick, and must not call the orchestrator.
+type StatsComponent interface {
+	Stats() map[string]interface{}
 }
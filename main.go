@@ -6,35 +6,104 @@ import (
 	"comps/comp/logger"
 	"comps/comp/users"
 	"comps/core"
+	"comps/core/comp/bus"
+	"comps/core/comp/deadletter"
 	"comps/core/comp/debug"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
-	"time"
+	"strings"
 )
 
 func main() {
+	configFile := flag.String("config", "", "JSON file containing component configuration, re-read on SIGHUP")
+	snapshotDir := flag.String("snapshots", "", "directory in which to persist component snapshots")
+	providers := map[core.Service]core.ComponentPath{}
+	flag.Func("provider", "`service=path` selecting the provider of a service (repeatable)", func(s string) error {
+		service, path, found := strings.Cut(s, "=")
+		if !found {
+			return fmt.Errorf("expected service=path, got %q", s)
+		}
+		providers[core.Service(service)] = core.ComponentPath(path)
+		return nil
+	})
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [flags] [plan [-json]]\n\n", os.Args[0])
+		fmt.Fprintf(out, "With no command, run the components.  The plan command prints what would\n")
+		fmt.Fprintf(out, "be started and stopped, and any problems, without starting anything.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// only Main may start or move the debug server
+	debugMain := debug.Main
+	debugMain.Access = core.AccessPolicy{
+		{Message: debug.Serve{}, Callers: []core.ComponentPath{componentPath}},
+		{Message: debug.RegisterHandler{}},
+		{Message: debug.HandlerRequest{}},
+	}
+
 	orch := core.NewOrchestrator(
 		Main,
 		logger.Main,
 		listen.Main,
 		conns.Main,
 		users.Main,
-		debug.Main,
+		debugMain,
 		debug.Expvar,
 		debug.Orchestrator,
+		debug.Goroutines,
+		deadletter.Main,
+		bus.Main,
 	)
-	err := orch.Start()
-	if err != nil {
-		fmt.Printf("Uhoh: %s\n", err)
-		os.Exit(1)
+	if *configFile != "" {
+		orch.SetConfigSource(core.FileConfigSource(*configFile))
+	}
+	if *snapshotDir != "" {
+		orch.SetSnapshotStore(core.DirSnapshotStore(*snapshotDir))
+	}
+	for service, path := range providers {
+		orch.SetProvider(service, path)
 	}
 
-	time.Sleep(15 * time.Second)
-	fmt.Printf("time's up\n")
-	orch.Stop(context.Background())
-	fmt.Printf("DONE (but waiting so you can check everything's stopped!)\n")
-	time.Sleep(15 * time.Second)
+	switch flag.Arg(0) {
+	case "":
+		os.Exit(orch.Run(context.Background()))
+	case "plan":
+		os.Exit(plan(orch, flag.Args()[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(core.ExitFailure)
+	}
+}
+
+// plan implements the `plan` command, printing what Start would do without
+// starting anything, and returning an exit status that indicates whether any
+// problems were found.
+func plan(orch *core.Orchestrator, args []string) int {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the plan as JSON")
+	flags.Parse(args)
+
+	p := orch.Plan()
+	if *asJSON {
+		out, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return core.ExitFailure
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(p)
+	}
+	if len(p.Problems) > 0 {
+		return core.ExitFailure
+	}
+	return core.ExitSuccess
 }
 
 var componentPath core.ComponentPath = "Main"
@@ -42,35 +111,101 @@ var componentPath core.ComponentPath = "Main"
 var Main = core.ComponentImpl{
 	Path: componentPath,
 	Dependencies: []core.ComponentPath{
-		"comp/logger.Main",
 		"comp/listen.Main",
 		"core/comp/debug.Main",
-		"core/comp/debug.Expvar",
-		"core/comp/debug.Orchestrator",
+		"core/comp/bus.Main",
 	},
-	Start: func(orch *core.Orchestrator, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
-		deps["comp/logger.Main"].RequestAsync(ctx, logger.Output{Message: "Debug on http://127.0.0.1:8080"})
-		deps["core/comp/debug.Main"].RequestAsync(ctx, debug.Serve{Port: 8080})
+	Requires:    []core.Service{logger.Service},
+	RequiresAll: []core.Service{debug.PageService}, // started for their side effects
+	CheckConfig: func(c core.Config) error {
+		return c.Decode(&Config{})
+	},
+	Capabilities: []core.Capability{core.LifecycleCapability},
+	Start: func(host core.Host, ctx context.Context, deps map[core.ComponentPath]core.ComponentReference) core.Component {
+		config := Config{DebugPort: 8080}
+		if err := host.Config().Decode(&config); err != nil {
+			host.Lifecycle().RequestShutdown(fmt.Errorf("%s: %w", componentPath, err))
+		}
+		deps["core/comp/bus.Main"].RequestAsync(ctx, bus.Subscribe{
+			Topic:      "users.*",
+			Subscriber: host.Service(logger.Service),
+			Until:      ctx.Done(),
+		})
+		deps["core/comp/bus.Main"].RequestAsync(ctx, bus.Subscribe{
+			Topic:      "lifecycle.**",
+			Subscriber: host.Service(logger.Service),
+			Until:      ctx.Done(),
+		})
 		deps["comp/listen.Main"].RequestAsync(ctx, listen.Run{})
-		return &comp{}
+		l := &comp{
+			logger:  host.Service(logger.Service),
+			debug:   deps["core/comp/debug.Main"],
+			mailbox: core.NewMailbox(core.MailboxConfig{Capacity: 10, Overflow: core.RejectOverflow}),
+			ctx:     ctx,
+			done:    make(chan struct{}),
+		}
+		l.serveDebug(config.DebugPort)
+		go l.run()
+		return l
 	},
 }
 
+// Config is the configuration for the Main component
+type Config struct {
+	// DebugPort is the port on which the debug server listens
+	DebugPort int `json:"debugPort"`
+}
+
 type comp struct {
 	core.BaseComponent
+	logger  core.ComponentReference
+	debug   core.ComponentReference
+	mailbox *core.Mailbox
+	ctx     context.Context
+	done    chan struct{}
 }
 
 var _ core.Component = &comp{}
 var _ core.ComponentReference = &comp{}
+var _ core.MailboxComponent = &comp{}
 
 // NewReference implements core.Component#NewReference.
 func (l *comp) NewReference() core.ComponentReference {
 	return l
 }
 
+// Done implements core.Component#Done.
+func (l *comp) Done() <-chan struct{} {
+	return l.done
+}
+
+// Mailbox implements core.MailboxComponent#Mailbox.
+func (l *comp) Mailbox() *core.Mailbox {
+	return l.mailbox
+}
+
+func (l *comp) run() {
+	defer close(l.done)
+	for {
+		select {
+		case env := <-l.mailbox.C():
+			core.HandleEnvelope(env, l.Request)
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
 // Request implements core.ComponentReference#Request.
 func (l *comp) Request(ctx context.Context, msg core.Message) (core.Message, error) {
-	switch msg.(type) {
+	switch v := msg.(type) {
+	case core.Reconfigure:
+		config := Config{DebugPort: 8080}
+		if err := v.Config.Decode(&config); err != nil {
+			return nil, err
+		}
+		l.serveDebug(config.DebugPort)
+		return nil, nil
 	default:
 		return nil, fmt.Errorf("Unrecognized message type %T", msg)
 	}
@@ -78,5 +213,12 @@ func (l *comp) Request(ctx context.Context, msg core.Message) (core.Message, err
 
 // RequestAsync implements core.ComponentReference#RequestAsync.
 func (l *comp) RequestAsync(ctx context.Context, msg core.Message) {
-	go l.Request(ctx, msg)
+	if err := l.mailbox.Put(ctx, msg); err != nil {
+		core.ReportAsyncFailure(ctx, err)
+	}
+}
+
+func (l *comp) serveDebug(port int) {
+	l.logger.RequestAsync(l.ctx, logger.Output{Message: fmt.Sprintf("Debug on http://127.0.0.1:%d", port)})
+	l.debug.RequestAsync(l.ctx, debug.Serve{Port: port})
 }